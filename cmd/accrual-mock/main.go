@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/accrualmock"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/httpserver"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+func main() {
+	mainLog := logger.NewFallbackLogger()
+	defer mainLog.Sync()
+
+	if err := run(mainLog); err != nil {
+		mainLog.Fatal("accrual mock failed:", zap.Error(err))
+	}
+}
+
+func run(mainLog *zap.Logger) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var addr, configPath string
+	flag.StringVar(&addr, "a", "localhost:8081", "address of mock accrual server")
+	flag.StringVar(&configPath, "c", "", "path to JSON file with mock rules")
+	flag.Parse()
+
+	if envAddr, ok := os.LookupEnv("RUN_ADDRESS"); ok && envAddr != "" {
+		addr = envAddr
+	}
+	if envConfig, ok := os.LookupEnv("MOCK_CONFIG"); ok && envConfig != "" {
+		configPath = envConfig
+	}
+
+	cfg := accrualmock.DefaultConfig()
+	if configPath != "" {
+		var err error
+		cfg, err = accrualmock.LoadConfig(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+	}
+
+	srv := accrualmock.New(cfg)
+	return httpserver.StartServer(ctx, addr, srv.Handler(), mainLog)
+}
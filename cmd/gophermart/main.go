@@ -2,26 +2,32 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/configs"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/handlers"
-	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/httpclient"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/health"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/httpserver"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/jobqueue"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/jwtmanager"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/leader"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logger"
-	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/worker"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/metrics"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/providers"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/scheduler"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/tracing"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/repositories"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/services"
 	"go.uber.org/zap"
 )
 
 func main() {
-	mainLog := zap.NewExample()
+	mainLog := logger.NewFallbackLogger()
 	defer mainLog.Sync()
 
 	if err := run(); err != nil {
@@ -38,12 +44,28 @@ func run() error {
 		return fmt.Errorf("failed to get config: %w", err)
 	}
 
-	zLog, err := logger.NewLogger(cfg)
+	zLog, logLevel, err := logger.NewLogger(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to initialize logger: %w", err)
 	}
 	defer zLog.Sync()
 
+	for _, msg := range cfg.Deprecations {
+		zLog.Warn(msg)
+	}
+
+	shutdownTracing, err := tracing.Init(ctx, "gophermart", cfg.TracingExporter, cfg.OTLPEndpoint)
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			zLog.Warn("failed to flush traces", zap.Error(err))
+		}
+	}()
+
 	dbLog := zLog.Named("database")
 	httpLog := zLog.Named("http")
 	clientLog := zLog.Named("client")
@@ -61,70 +83,204 @@ func run() error {
 	authSvc := services.NewAuthService(repo, jwtMgr)
 	authH := handlers.NewAuthHandler(authSvc, httpLog)
 
-	ordersSvc := services.NewOrdersService(repo)
+	accrualProviders, err := providers.NewRegistry(registryOptions(cfg.AccrualProviders), clientLog)
+	if err != nil {
+		return fmt.Errorf("failed to initialize accrual providers: %w", err)
+	}
+
+	ordersSvc := services.NewOrdersService(repo, accrualProviders)
 	ordersH := handlers.NewOrdersHandler(ordersSvc, httpLog)
 
 	balanceSvc := services.NewBalanceService(repo)
 	balanceH := handlers.NewBalanceHandler(balanceSvc, httpLog)
 
-	router := handlers.NewRouter(httpLog, jwtMgr, authH, ordersH, balanceH)
+	transactionsSvc := services.NewTransactionsService(repo)
+	transactionsH := handlers.NewTransactionsHandler(transactionsSvc, httpLog)
+
+	statementSvc := services.NewStatementService(repo)
+	statementH := handlers.NewStatementHandler(statementSvc, httpLog)
+
+	adminSvc := services.NewAdminService(repo)
+	adminH := handlers.NewAdminHandler(adminSvc, httpLog)
+
+	apiKeysSvc := services.NewAPIKeysService(repo)
+	apiKeysH := handlers.NewAPIKeysHandler(apiKeysSvc, httpLog)
+
+	var pollProviders []services.AccrualProvider
+	for _, p := range accrualProviders.Providers() {
+		pollProviders = append(pollProviders, services.AccrualProvider{Name: p.Name, Client: p.Client, Limiter: p.Limiter})
+	}
+	accrualSvc := services.NewAccrualService(pollProviders, repo, clientLog, services.AccrualOptions{
+		BatchSize: cfg.BatchSize,
+		Owner:     cfg.InstanceID,
+		LeaseTTL:  cfg.LeaseTTL,
+		Poll: services.PollPolicy{
+			BaseDelay:   cfg.PollBackoffBase,
+			MaxDelay:    cfg.PollBackoffMax,
+			MaxAttempts: cfg.PollMaxAttempts,
+		},
+	})
+
+	if err = accrualSvc.AdoptOrphanedOrders(ctx, accrualProviders.Default()); err != nil {
+		clientLog.Error("failed to reassign orders of unknown accrual providers", zap.Error(err))
+		return err
+	}
+
+	accrualCallbackH := handlers.NewAccrualCallbackHandler(accrualSvc, httpLog)
+	logLevelH := handlers.NewLogLevelHandler(logLevel, adminSvc, httpLog)
+
+	healthChecker := health.NewChecker(2*time.Second, httpLog)
+	healthChecker.Add("database", repo.Ping)
+	healthChecker.Add("migrations", repo.CheckMigrations)
+	healthChecker.Add("accrual", func(context.Context) error {
+		if !accrualSvc.Available() {
+			return models.ErrAccrualUnavailable
+		}
+		return nil
+	})
+	healthChecker.Add("poller", pollerHeartbeat(accrualSvc, cfg.PollInterval))
 
-	accrualClient := httpclient.NewAccrualClient(cfg.AccrualAddr)
-	accrualSvc := services.NewAccrualService(accrualClient, repo, clientLog, cfg.BatchSize)
+	router := handlers.NewRouter(httpLog, jwtMgr, apiKeysSvc, authH, ordersH, balanceH, transactionsH, statementH, adminH, apiKeysH, accrualCallbackH, logLevelH, []byte(cfg.AccrualWebhookSecret), healthChecker)
+
+	reconcileSvc := services.NewReconcileService(pollProviders, repo, clientLog, services.ReconcileOptions{
+		Window:      cfg.ReconcileWindow,
+		BatchSize:   cfg.ReconcileBatchSize,
+		AutoCorrect: cfg.ReconcileAutoCorrect,
+	})
+
+	elector := leader.New(repo, "gophermart", cfg.InstanceID, cfg.LeaderTTL, zLog.Named("leader"))
+	electorDone := make(chan struct{})
+	go func() {
+		defer close(electorDone)
+		elector.Run(ctx)
+	}()
+	defer func() { <-electorDone }()
+
+	jobsLog := zLog.Named("jobs")
+	queue := jobqueue.New(repo, jobsLog, jobqueue.Options{
+		Owner:   cfg.InstanceID,
+		Leader:  elector,
+		Workers: cfg.JobWorkers,
+	})
+	queue.Register(jobAccrualPoll, accrualPollJob(accrualSvc, clientLog))
+	queue.Schedule(jobqueue.Periodic{Type: jobAccrualPoll, Key: cfg.InstanceID, Every: cfg.PollInterval, Priority: 10, Local: true})
+
+	sched := scheduler.New(repo, queue, elector, zLog.Named("scheduler"))
+	tasks := []scheduler.Task{
+		{Name: "accrual.reconcile", Spec: cfg.ReconcileSchedule, Run: reconcileTask(reconcileSvc, clientLog)},
+		{Name: "jobs.purge", Spec: cfg.JobsPurgeSchedule, Run: purgeDeadJobsTask(repo, jobsLog)},
+	}
+	for _, t := range tasks {
+		if err = sched.Add(t); err != nil {
+			return err
+		}
+	}
+	go sched.Run(ctx)
 
-	wp := worker.NewWorkerPool(cfg.RateLimit)
-	wp.Start()
-	defer wp.Stop()
+	metrics.MustRegister(
+		metrics.NewOrdersCollector(repo, dbLog),
+		metrics.NewPoolCollector(repo.Stat),
+		metrics.NewRateCollector(accrualSvc.Rates),
+		metrics.NewWorkerCollector("jobs", queue.Stats),
+	)
 
-	go startAccrualPoller(ctx, wp, accrualSvc, cfg.PollInterval, clientLog)
+	queueDone := make(chan struct{})
+	go func() {
+		defer close(queueDone)
+		queue.Run(ctx)
+	}()
+	defer func() { <-queueDone }()
 
-	if err = httpserver.StartServer(ctx, cfg.RunAddr, router, srvLog); err != nil {
+	if err = httpserver.StartServer(ctx, cfg.RunAddr, router, srvLog, httpserver.WithDrain(cfg.DrainDelay, healthChecker.SetDraining)); err != nil {
 		srvLog.Error("server failed", zap.Error(err))
 	}
 
 	return err
 }
 
-func startAccrualPoller(ctx context.Context, wp *worker.WorkerPool, svc *services.AccrualService, d time.Duration, cLog *zap.Logger) {
-	ticker := time.NewTicker(d)
-	defer ticker.Stop()
-	var (
-		sleepUntil time.Time
-		mu         sync.Mutex
-	)
+const (
+	jobAccrualPoll   = "accrual.poll"
+	deadJobRetention = 7 * 24 * time.Hour
+	orphanedJobAge   = time.Hour
+)
+
+func registryOptions(cfg *configs.AccrualProvidersConfig) providers.Options {
+	opts := providers.Options{Default: cfg.Default}
+	for _, pc := range cfg.Providers {
+		ep := providers.Endpoint{
+			Name:             pc.Name,
+			Address:          pc.Address,
+			RPS:              pc.RPS,
+			MaxInFlight:      pc.MaxInFlight,
+			BreakerThreshold: pc.BreakerThreshold,
+			BreakerTimeout:   pc.BreakerTimeout,
+		}
+		if pc.Batch {
+			ep.BatchSize = pc.BatchSize
+		}
+		opts.Endpoints = append(opts.Endpoints, ep)
+	}
+	for _, rc := range cfg.Routes {
+		opts.Routes = append(opts.Routes, providers.Route{Prefix: rc.Prefix, APIKeyID: rc.APIKeyID, Provider: rc.Provider})
+	}
+	return opts
+}
+
+func accrualPollJob(svc *services.AccrualService, cLog *zap.Logger) jobqueue.Handler {
+	return func(ctx context.Context, _ *models.Job) error {
+		processed, err := svc.PollAndUpdate(ctx)
+		if errors.Is(err, models.ErrAccrualUnavailable) {
+			cLog.Debug("accrual poll suppressed: circuit breakers of all providers are open")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if processed == 0 {
+			cLog.Debug("no orders to process")
+			return nil
+		}
+		cLog.Debug("accrual poll updated", zap.Int("processed", processed), zap.Any("rps", svc.Rates()))
+		return nil
+	}
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			cLog.Debug("stopping accrual poller")
-			return
-		case <-ticker.C:
-			mu.Lock()
-			shouldSleep := !sleepUntil.IsZero() && time.Now().Before(sleepUntil)
-			mu.Unlock()
-			if shouldSleep {
-				continue
-			}
-
-			wp.Submit(func() {
-				processed, retryAfter, err := svc.PollAndUpdate(ctx)
-				if err != nil {
-					cLog.Warn("accrual poll failed", zap.Error(err))
-					return
-				}
-				if retryAfter > 0 {
-					cLog.Debug("retrying accrual poll", zap.Duration("retry_after", retryAfter))
-					mu.Lock()
-					sleepUntil = time.Now().Add(retryAfter)
-					mu.Unlock()
-					return
-				}
-				if processed == 0 {
-					cLog.Debug("no orders to process")
-					return
-				}
-				cLog.Debug("accrual poll updated", zap.Int("processed", processed))
-			})
+func reconcileTask(svc *services.ReconcileService, cLog *zap.Logger) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		checked, found, err := svc.Reconcile(ctx)
+		if errors.Is(err, models.ErrAccrualUnavailable) {
+			cLog.Debug("accrual reconciliation skipped: circuit breakers of all providers are open")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cLog.Info("accrual reconciliation finished", zap.Int("checked", checked), zap.Int("discrepancies", found))
+		return nil
+	}
+}
+
+func pollerHeartbeat(svc *services.AccrualService, interval time.Duration) health.Check {
+	maxAge := max(3*interval, 30*time.Second)
+	return func(context.Context) error {
+		if age := time.Since(svc.LastPoll()); age > maxAge {
+			return fmt.Errorf("last accrual poll %s ago", age.Round(time.Second))
+		}
+		return nil
+	}
+}
+
+func purgeDeadJobsTask(repo *repositories.DB, jLog *zap.Logger) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		purged, err := repo.PurgeDeadJobs(ctx, time.Now().Add(-deadJobRetention))
+		if err != nil {
+			return err
+		}
+		orphaned, err := repo.PurgeOrphanedJobs(ctx, time.Now().Add(-orphanedJobAge))
+		if err != nil {
+			return err
 		}
+		jLog.Info("dead jobs purged", zap.Int64("purged", purged), zap.Int64("orphaned", orphaned))
+		return nil
 	}
 }
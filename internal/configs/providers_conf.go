@@ -0,0 +1,110 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const DefaultAccrualProvider = "default"
+
+type AccrualProviderConfig struct {
+	Name              string        `json:"name"`
+	Address           string        `json:"address"`
+	RPS               float64       `json:"rps"`
+	MaxInFlight       int           `json:"max_in_flight"`
+	BreakerThreshold  int           `json:"breaker_threshold"`
+	BreakerTimeout    time.Duration `json:"-"`
+	BreakerTimeoutSec int64         `json:"breaker_timeout"`
+	Batch             bool          `json:"batch"`
+	BatchSize         int           `json:"batch_size"`
+}
+
+type AccrualRouteConfig struct {
+	Prefix   string `json:"prefix"`
+	APIKeyID int64  `json:"api_key_id"`
+	Provider string `json:"provider"`
+}
+
+type AccrualProvidersConfig struct {
+	Default   string                  `json:"default"`
+	Providers []AccrualProviderConfig `json:"providers"`
+	Routes    []AccrualRouteConfig    `json:"routes"`
+}
+
+func loadAccrualProviders(path string, cfg *ServerConfig) (*AccrualProvidersConfig, error) {
+	if path == "" {
+		return &AccrualProvidersConfig{
+			Default: DefaultAccrualProvider,
+			Providers: []AccrualProviderConfig{{
+				Name:             DefaultAccrualProvider,
+				Address:          cfg.AccrualAddr,
+				RPS:              cfg.AccrualRPS,
+				MaxInFlight:      cfg.AccrualMaxInFlight,
+				BreakerThreshold: cfg.BreakerThreshold,
+				BreakerTimeout:   cfg.BreakerTimeout,
+				Batch:            cfg.AccrualBatch,
+				BatchSize:        cfg.BatchSize,
+			}},
+		}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read accrual providers file %q: %w", path, err)
+	}
+
+	var pc AccrualProvidersConfig
+	if err = json.Unmarshal(data, &pc); err != nil {
+		return nil, fmt.Errorf("failed to parse accrual providers file %q: %w", path, err)
+	}
+
+	if pc.Default == "" {
+		pc.Default = DefaultAccrualProvider
+	}
+
+	names := make(map[string]struct{}, len(pc.Providers))
+	for i := range pc.Providers {
+		p := &pc.Providers[i]
+		if p.Name == "" || p.Address == "" {
+			return nil, fmt.Errorf("invalid accrual provider #%d: name and address are required", i+1)
+		}
+		if _, ok := names[p.Name]; ok {
+			return nil, fmt.Errorf("invalid accrual provider %q: duplicate name", p.Name)
+		}
+		names[p.Name] = struct{}{}
+
+		if p.RPS <= 0 {
+			p.RPS = cfg.AccrualRPS
+		}
+		if p.MaxInFlight <= 0 {
+			p.MaxInFlight = cfg.AccrualMaxInFlight
+		}
+		if p.BreakerThreshold <= 0 {
+			p.BreakerThreshold = cfg.BreakerThreshold
+		}
+		if p.BatchSize <= 0 {
+			p.BatchSize = cfg.BatchSize
+		}
+		p.BreakerTimeout = cfg.BreakerTimeout
+		if p.BreakerTimeoutSec > 0 {
+			p.BreakerTimeout = time.Duration(p.BreakerTimeoutSec) * time.Second
+		}
+	}
+
+	if _, ok := names[pc.Default]; !ok {
+		return nil, fmt.Errorf("invalid accrual providers config: default provider %q is not defined", pc.Default)
+	}
+
+	for i, r := range pc.Routes {
+		if (r.Prefix == "") == (r.APIKeyID == 0) {
+			return nil, fmt.Errorf("invalid accrual route #%d: exactly one of prefix and api_key_id is required", i+1)
+		}
+		if _, ok := names[r.Provider]; !ok {
+			return nil, fmt.Errorf("invalid accrual route #%d: provider %q is not defined", i+1, r.Provider)
+		}
+	}
+
+	return &pc, nil
+}
@@ -8,34 +8,120 @@ import (
 	"time"
 )
 
+const DefaultLogRedact = "msg=digits,order=mask,number=mask,login=hash,remote_addr=ip,query=query,path=digits,error=digits,dsn=dsn"
+
 type ServerConfig struct {
-	LogLevel     string
+	LogLevel          string
+	LogMode           string
+	LogEncoding       string
+	LogSampling       bool
+	LogOutput         string
+	LogMaxSize        int
+	LogRotateInterval time.Duration
+	LogMaxBackups     int
+	LogRedact         string
+	LogHashKey        string
+
 	RunAddr      string
 	DatabaseURI  string
 	AccrualAddr  string
 	Secret       string
 	BatchSize    int
 	RateLimit    int
+	JobWorkers   int
 	TokenTTL     time.Duration
 	PollInterval time.Duration
+
+	PollBackoffBase time.Duration
+	PollBackoffMax  time.Duration
+	PollMaxAttempts int
+
+	InstanceID string
+	LeaseTTL   time.Duration
+	LeaderTTL  time.Duration
+
+	AccrualRPS         float64
+	AccrualMaxInFlight int
+	AccrualBatch       bool
+
+	BreakerThreshold int
+	BreakerTimeout   time.Duration
+
+	AccrualProvidersFile string
+	AccrualProviders     *AccrualProvidersConfig
+
+	AccrualWebhookSecret string
+
+	ReconcileSchedule    string
+	ReconcileWindow      time.Duration
+	ReconcileBatchSize   int
+	ReconcileAutoCorrect bool
+
+	JobsPurgeSchedule string
+
+	TracingExporter string
+	OTLPEndpoint    string
+
+	DrainDelay time.Duration
+
+	Deprecations []string
 }
 
 func GetConfig() (*ServerConfig, error) {
 	var (
-		cfg          ServerConfig
-		tokenTTL     int64
-		pollInterval int64
+		cfg             ServerConfig
+		tokenTTL        int64
+		pollInterval    int64
+		pollBackoffBase int64
+		pollBackoffMax  int64
+		leaseTTL        int64
+		leaderTTL       int64
+		drainDelay      int64
+		breakerTimeout  int64
+		reconcileWindow int64
+		logRotate       int64
 	)
 
 	flag.StringVar(&cfg.LogLevel, "l", "info", "log level")
+	flag.StringVar(&cfg.LogMode, "log-mode", "dev", "logger mode: dev or prod")
+	flag.StringVar(&cfg.LogEncoding, "log-encoding", "", "log encoding: json or console, defaults to json in prod mode and console in dev mode")
+	flag.BoolVar(&cfg.LogSampling, "log-sampling", false, "sample repeated log entries")
+	flag.StringVar(&cfg.LogOutput, "log-output", "stderr", "comma-separated log outputs: stdout, stderr or file paths")
+	flag.IntVar(&cfg.LogMaxSize, "log-max-size", 100, "max size of a log file before rotation in megabytes, 0 disables")
+	flag.Int64Var(&logRotate, "log-rotate-interval", 24, "log file rotation interval in hours, 0 disables")
+	flag.IntVar(&cfg.LogMaxBackups, "log-max-backups", 7, "number of rotated log files to keep, 0 keeps all")
+	flag.StringVar(&cfg.LogRedact, "log-redact", DefaultLogRedact, "comma-separated log redaction rules field=strategy, none disables")
+	flag.StringVar(&cfg.LogHashKey, "log-hash-key", "", "key for hashed log fields, random per process if empty")
 	flag.StringVar(&cfg.RunAddr, "a", "localhost:8080", "address of HTTP server")
 	flag.StringVar(&cfg.DatabaseURI, "d", "", "database PostgreSQL URI")
 	flag.StringVar(&cfg.AccrualAddr, "r", "", "address of the accrual calculation system")
 	flag.StringVar(&cfg.Secret, "s", "development-secret-change-me", "secret key for JWT")
 	flag.IntVar(&cfg.BatchSize, "b", 10, "batch size for accrual requests")
-	flag.IntVar(&cfg.RateLimit, "n", 5, "rate limit for accrual requests")
+	flag.IntVar(&cfg.RateLimit, "n", 0, "rate limit for accrual requests, deprecated: use -accrual-max-inflight")
+	flag.IntVar(&cfg.JobWorkers, "job-workers", 5, "number of background job queue workers")
 	flag.Int64Var(&tokenTTL, "t", 24, "token TTL in hours")
 	flag.Int64Var(&pollInterval, "i", 1, "poll interval in seconds")
+	flag.Int64Var(&pollBackoffBase, "poll-backoff-base", 1, "base delay between polls of the same order in seconds")
+	flag.Int64Var(&pollBackoffMax, "poll-backoff-max", 600, "max delay between polls of the same order in seconds")
+	flag.IntVar(&cfg.PollMaxAttempts, "poll-max-attempts", 50, "max poll attempts before an order is parked for review")
+	flag.StringVar(&cfg.InstanceID, "instance-id", defaultInstanceID(), "unique identifier of this instance")
+	flag.Int64Var(&leaseTTL, "lease-ttl", 30, "order lease TTL for accrual polling in seconds")
+	flag.Int64Var(&leaderTTL, "leader-ttl", 15, "leader lease TTL for singleton background jobs in seconds")
+	flag.Float64Var(&cfg.AccrualRPS, "accrual-rps", 10, "max accrual requests per second")
+	flag.IntVar(&cfg.AccrualMaxInFlight, "accrual-max-inflight", 5, "max concurrent accrual requests")
+	flag.BoolVar(&cfg.AccrualBatch, "accrual-batch", false, "query accrual orders in batches of batch size")
+	flag.IntVar(&cfg.BreakerThreshold, "breaker-threshold", 5, "consecutive accrual failures before the circuit opens")
+	flag.Int64Var(&breakerTimeout, "breaker-timeout", 30, "time the accrual circuit stays open before a probe in seconds")
+	flag.StringVar(&cfg.AccrualProvidersFile, "accrual-providers", "", "path to JSON file with accrual providers and routing")
+	flag.StringVar(&cfg.ReconcileSchedule, "reconcile-schedule", "0 * * * *", "cron schedule of accrual reconciliation")
+	flag.StringVar(&cfg.JobsPurgeSchedule, "jobs-purge-schedule", "30 3 * * *", "cron schedule of dead jobs purge")
+	flag.Int64Var(&reconcileWindow, "reconcile-window", 168, "window of processed orders checked by reconciliation in hours")
+	flag.IntVar(&cfg.ReconcileBatchSize, "reconcile-batch", 50, "max orders checked per reconciliation run")
+	flag.BoolVar(&cfg.ReconcileAutoCorrect, "reconcile-auto-correct", false, "auto-correct accrual discrepancies with balance adjustments")
+	flag.Int64Var(&drainDelay, "drain-delay", 5, "time the server keeps serving with failing readiness before shutdown in seconds")
+	flag.StringVar(&cfg.TracingExporter, "tracing-exporter", "none", "trace exporter: none, stdout or otlp")
+	flag.StringVar(&cfg.OTLPEndpoint, "otlp-endpoint", "", "OTLP/HTTP traces endpoint URL")
+	flag.StringVar(&cfg.AccrualWebhookSecret, "accrual-webhook-secret", "", "HMAC secret for accrual callbacks, callbacks are disabled if empty")
 
 	flag.Parse()
 
@@ -43,6 +129,78 @@ func GetConfig() (*ServerConfig, error) {
 		cfg.LogLevel = envLogLevel
 	}
 
+	if envLogMode, ok := os.LookupEnv("LOG_MODE"); ok && envLogMode != "" {
+		cfg.LogMode = envLogMode
+	}
+	switch cfg.LogMode {
+	case "dev", "prod":
+	default:
+		return nil, fmt.Errorf("invalid LOG_MODE value %q: must be dev or prod", cfg.LogMode)
+	}
+
+	if envLogEncoding, ok := os.LookupEnv("LOG_ENCODING"); ok && envLogEncoding != "" {
+		cfg.LogEncoding = envLogEncoding
+	}
+	switch cfg.LogEncoding {
+	case "", "json", "console":
+	default:
+		return nil, fmt.Errorf("invalid LOG_ENCODING value %q: must be json or console", cfg.LogEncoding)
+	}
+
+	if envLogSampling, ok := os.LookupEnv("LOG_SAMPLING"); ok && envLogSampling != "" {
+		var err error
+		cfg.LogSampling, err = strconv.ParseBool(envLogSampling)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse LOG_SAMPLING value %q to bool: %w", envLogSampling, err)
+		}
+	}
+
+	if envLogOutput, ok := os.LookupEnv("LOG_OUTPUT"); ok && envLogOutput != "" {
+		cfg.LogOutput = envLogOutput
+	}
+
+	if envLogMaxSize, ok := os.LookupEnv("LOG_MAX_SIZE"); ok && envLogMaxSize != "" {
+		var err error
+		cfg.LogMaxSize, err = strconv.Atoi(envLogMaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse LOG_MAX_SIZE value %q to integer: %w", envLogMaxSize, err)
+		}
+		if cfg.LogMaxSize < 0 {
+			return nil, fmt.Errorf("invalid LOG_MAX_SIZE value %q: must not be negative", envLogMaxSize)
+		}
+	}
+
+	if envLogRotate, ok := os.LookupEnv("LOG_ROTATE_INTERVAL"); ok && envLogRotate != "" {
+		var err error
+		logRotate, err = strconv.ParseInt(envLogRotate, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse LOG_ROTATE_INTERVAL value %q to integer: %w", envLogRotate, err)
+		}
+		if logRotate < 0 {
+			return nil, fmt.Errorf("invalid LOG_ROTATE_INTERVAL value %q: must not be negative", envLogRotate)
+		}
+	}
+	cfg.LogRotateInterval = time.Duration(logRotate) * time.Hour
+
+	if envLogMaxBackups, ok := os.LookupEnv("LOG_MAX_BACKUPS"); ok && envLogMaxBackups != "" {
+		var err error
+		cfg.LogMaxBackups, err = strconv.Atoi(envLogMaxBackups)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse LOG_MAX_BACKUPS value %q to integer: %w", envLogMaxBackups, err)
+		}
+		if cfg.LogMaxBackups < 0 {
+			return nil, fmt.Errorf("invalid LOG_MAX_BACKUPS value %q: must not be negative", envLogMaxBackups)
+		}
+	}
+
+	if envLogRedact, ok := os.LookupEnv("LOG_REDACT"); ok && envLogRedact != "" {
+		cfg.LogRedact = envLogRedact
+	}
+
+	if envLogHashKey, ok := os.LookupEnv("LOG_HASH_KEY"); ok && envLogHashKey != "" {
+		cfg.LogHashKey = envLogHashKey
+	}
+
 	if envRunAddr, ok := os.LookupEnv("RUN_ADDRESS"); ok && envRunAddr != "" {
 		cfg.RunAddr = envRunAddr
 	}
@@ -81,6 +239,17 @@ func GetConfig() (*ServerConfig, error) {
 		}
 	}
 
+	if envJobWorkers, ok := os.LookupEnv("JOB_WORKERS"); ok && envJobWorkers != "" {
+		var err error
+		cfg.JobWorkers, err = strconv.Atoi(envJobWorkers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JOB_WORKERS value %q to integer: %w", envJobWorkers, err)
+		}
+		if cfg.JobWorkers <= 0 {
+			return nil, fmt.Errorf("invalid JOB_WORKERS value %q: must be positive", envJobWorkers)
+		}
+	}
+
 	if envTokenTTL, ok := os.LookupEnv("TOKEN_TTL"); ok && envTokenTTL != "" {
 		var err error
 		tokenTTL, err = strconv.ParseInt(envTokenTTL, 10, 64)
@@ -105,5 +274,228 @@ func GetConfig() (*ServerConfig, error) {
 	}
 	cfg.PollInterval = time.Duration(pollInterval) * time.Second
 
+	if envPollBackoffBase, ok := os.LookupEnv("POLL_BACKOFF_BASE"); ok && envPollBackoffBase != "" {
+		var err error
+		pollBackoffBase, err = strconv.ParseInt(envPollBackoffBase, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse POLL_BACKOFF_BASE value %q to integer: %w", envPollBackoffBase, err)
+		}
+	}
+
+	if envPollBackoffMax, ok := os.LookupEnv("POLL_BACKOFF_MAX"); ok && envPollBackoffMax != "" {
+		var err error
+		pollBackoffMax, err = strconv.ParseInt(envPollBackoffMax, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse POLL_BACKOFF_MAX value %q to integer: %w", envPollBackoffMax, err)
+		}
+	}
+
+	if envPollMaxAttempts, ok := os.LookupEnv("POLL_MAX_ATTEMPTS"); ok && envPollMaxAttempts != "" {
+		var err error
+		cfg.PollMaxAttempts, err = strconv.Atoi(envPollMaxAttempts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse POLL_MAX_ATTEMPTS value %q to integer: %w", envPollMaxAttempts, err)
+		}
+	}
+
+	if pollBackoffBase <= 0 {
+		return nil, fmt.Errorf("invalid poll backoff base %d: must be positive", pollBackoffBase)
+	}
+	if pollBackoffMax <= 0 {
+		return nil, fmt.Errorf("invalid poll backoff max %d: must be positive", pollBackoffMax)
+	}
+	if pollBackoffMax < pollBackoffBase {
+		return nil, fmt.Errorf("invalid poll backoff max %d: must not be less than base %d", pollBackoffMax, pollBackoffBase)
+	}
+	if cfg.PollMaxAttempts <= 0 {
+		return nil, fmt.Errorf("invalid poll max attempts %d: must be positive", cfg.PollMaxAttempts)
+	}
+	cfg.PollBackoffBase = time.Duration(pollBackoffBase) * time.Second
+	cfg.PollBackoffMax = time.Duration(pollBackoffMax) * time.Second
+
+	if envInstanceID, ok := os.LookupEnv("INSTANCE_ID"); ok && envInstanceID != "" {
+		cfg.InstanceID = envInstanceID
+	}
+
+	if envLeaseTTL, ok := os.LookupEnv("LEASE_TTL"); ok && envLeaseTTL != "" {
+		var err error
+		leaseTTL, err = strconv.ParseInt(envLeaseTTL, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse LEASE_TTL value %q to integer: %w", envLeaseTTL, err)
+		}
+		if leaseTTL <= 0 {
+			return nil, fmt.Errorf("invalid LEASE_TTL value %q: must be positive", envLeaseTTL)
+		}
+	}
+	cfg.LeaseTTL = time.Duration(leaseTTL) * time.Second
+
+	if envLeaderTTL, ok := os.LookupEnv("LEADER_TTL"); ok && envLeaderTTL != "" {
+		var err error
+		leaderTTL, err = strconv.ParseInt(envLeaderTTL, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse LEADER_TTL value %q to integer: %w", envLeaderTTL, err)
+		}
+		if leaderTTL <= 0 {
+			return nil, fmt.Errorf("invalid LEADER_TTL value %q: must be positive", envLeaderTTL)
+		}
+	}
+	cfg.LeaderTTL = time.Duration(leaderTTL) * time.Second
+
+	if envAccrualRPS, ok := os.LookupEnv("ACCRUAL_RPS"); ok && envAccrualRPS != "" {
+		var err error
+		cfg.AccrualRPS, err = strconv.ParseFloat(envAccrualRPS, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ACCRUAL_RPS value %q to float: %w", envAccrualRPS, err)
+		}
+		if cfg.AccrualRPS <= 0 {
+			return nil, fmt.Errorf("invalid ACCRUAL_RPS value %q: must be positive", envAccrualRPS)
+		}
+	}
+
+	if envAccrualMaxInFlight, ok := os.LookupEnv("ACCRUAL_MAX_INFLIGHT"); ok && envAccrualMaxInFlight != "" {
+		var err error
+		cfg.AccrualMaxInFlight, err = strconv.Atoi(envAccrualMaxInFlight)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ACCRUAL_MAX_INFLIGHT value %q to integer: %w", envAccrualMaxInFlight, err)
+		}
+		if cfg.AccrualMaxInFlight <= 0 {
+			return nil, fmt.Errorf("invalid ACCRUAL_MAX_INFLIGHT value %q: must be positive", envAccrualMaxInFlight)
+		}
+	}
+
+	if cfg.RateLimit > 0 {
+		cfg.Deprecations = append(cfg.Deprecations, "-n/RATE_LIMIT is deprecated, use -accrual-max-inflight/ACCRUAL_MAX_INFLIGHT")
+		if os.Getenv("ACCRUAL_MAX_INFLIGHT") == "" && !flagPassed("accrual-max-inflight") {
+			cfg.AccrualMaxInFlight = cfg.RateLimit
+		}
+	}
+
+	if envAccrualBatch, ok := os.LookupEnv("ACCRUAL_BATCH"); ok && envAccrualBatch != "" {
+		var err error
+		cfg.AccrualBatch, err = strconv.ParseBool(envAccrualBatch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ACCRUAL_BATCH value %q to bool: %w", envAccrualBatch, err)
+		}
+	}
+
+	if envBreakerThreshold, ok := os.LookupEnv("BREAKER_THRESHOLD"); ok && envBreakerThreshold != "" {
+		var err error
+		cfg.BreakerThreshold, err = strconv.Atoi(envBreakerThreshold)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse BREAKER_THRESHOLD value %q to integer: %w", envBreakerThreshold, err)
+		}
+		if cfg.BreakerThreshold <= 0 {
+			return nil, fmt.Errorf("invalid BREAKER_THRESHOLD value %q: must be positive", envBreakerThreshold)
+		}
+	}
+
+	if envBreakerTimeout, ok := os.LookupEnv("BREAKER_TIMEOUT"); ok && envBreakerTimeout != "" {
+		var err error
+		breakerTimeout, err = strconv.ParseInt(envBreakerTimeout, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse BREAKER_TIMEOUT value %q to integer: %w", envBreakerTimeout, err)
+		}
+		if breakerTimeout <= 0 {
+			return nil, fmt.Errorf("invalid BREAKER_TIMEOUT value %q: must be positive", envBreakerTimeout)
+		}
+	}
+	cfg.BreakerTimeout = time.Duration(breakerTimeout) * time.Second
+
+	if envProvidersFile, ok := os.LookupEnv("ACCRUAL_PROVIDERS_FILE"); ok && envProvidersFile != "" {
+		cfg.AccrualProvidersFile = envProvidersFile
+	}
+
+	if envWebhookSecret, ok := os.LookupEnv("ACCRUAL_WEBHOOK_SECRET"); ok && envWebhookSecret != "" {
+		cfg.AccrualWebhookSecret = envWebhookSecret
+	}
+
+	if envReconcileSchedule, ok := os.LookupEnv("RECONCILE_SCHEDULE"); ok && envReconcileSchedule != "" {
+		cfg.ReconcileSchedule = envReconcileSchedule
+	}
+
+	if envJobsPurgeSchedule, ok := os.LookupEnv("JOBS_PURGE_SCHEDULE"); ok && envJobsPurgeSchedule != "" {
+		cfg.JobsPurgeSchedule = envJobsPurgeSchedule
+	}
+
+	if envReconcileWindow, ok := os.LookupEnv("RECONCILE_WINDOW"); ok && envReconcileWindow != "" {
+		var err error
+		reconcileWindow, err = strconv.ParseInt(envReconcileWindow, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RECONCILE_WINDOW value %q to integer: %w", envReconcileWindow, err)
+		}
+		if reconcileWindow <= 0 {
+			return nil, fmt.Errorf("invalid RECONCILE_WINDOW value %q: must be positive", envReconcileWindow)
+		}
+	}
+	cfg.ReconcileWindow = time.Duration(reconcileWindow) * time.Hour
+
+	if envReconcileBatch, ok := os.LookupEnv("RECONCILE_BATCH"); ok && envReconcileBatch != "" {
+		var err error
+		cfg.ReconcileBatchSize, err = strconv.Atoi(envReconcileBatch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RECONCILE_BATCH value %q to integer: %w", envReconcileBatch, err)
+		}
+		if cfg.ReconcileBatchSize <= 0 {
+			return nil, fmt.Errorf("invalid RECONCILE_BATCH value %q: must be positive", envReconcileBatch)
+		}
+	}
+
+	if envReconcileAutoCorrect, ok := os.LookupEnv("RECONCILE_AUTO_CORRECT"); ok && envReconcileAutoCorrect != "" {
+		var err error
+		cfg.ReconcileAutoCorrect, err = strconv.ParseBool(envReconcileAutoCorrect)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RECONCILE_AUTO_CORRECT value %q to bool: %w", envReconcileAutoCorrect, err)
+		}
+	}
+
+	if envTracingExporter, ok := os.LookupEnv("TRACING_EXPORTER"); ok && envTracingExporter != "" {
+		cfg.TracingExporter = envTracingExporter
+	}
+	switch cfg.TracingExporter {
+	case "none", "stdout", "otlp":
+	default:
+		return nil, fmt.Errorf("invalid TRACING_EXPORTER value %q: must be none, stdout or otlp", cfg.TracingExporter)
+	}
+
+	if envOTLPEndpoint, ok := os.LookupEnv("OTLP_ENDPOINT"); ok && envOTLPEndpoint != "" {
+		cfg.OTLPEndpoint = envOTLPEndpoint
+	}
+
+	if envDrainDelay, ok := os.LookupEnv("DRAIN_DELAY"); ok && envDrainDelay != "" {
+		var err error
+		drainDelay, err = strconv.ParseInt(envDrainDelay, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DRAIN_DELAY value %q to integer: %w", envDrainDelay, err)
+		}
+		if drainDelay < 0 {
+			return nil, fmt.Errorf("invalid DRAIN_DELAY value %q: must not be negative", envDrainDelay)
+		}
+	}
+	cfg.DrainDelay = time.Duration(drainDelay) * time.Second
+
+	var err error
+	cfg.AccrualProviders, err = loadAccrualProviders(cfg.AccrualProvidersFile, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
+
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "gophermart"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
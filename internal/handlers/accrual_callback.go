@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"go.uber.org/zap"
+)
+
+type AccrualCallbackService interface {
+	ApplyAccrual(ctx context.Context, accrualResp *models.AccrualResp) error
+}
+
+type AccrualCallbackHandler struct {
+	accrualSvc AccrualCallbackService
+	logger     *zap.Logger
+}
+
+func NewAccrualCallbackHandler(accrualSvc AccrualCallbackService, logger *zap.Logger) *AccrualCallbackHandler {
+	return &AccrualCallbackHandler{
+		accrualSvc: accrualSvc,
+		logger:     logger.With(zap.String("handler", "accrual_callback")),
+	}
+}
+
+func (ach *AccrualCallbackHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var accrualResp models.AccrualResp
+	if err := json.NewDecoder(r.Body).Decode(&accrualResp); err != nil || accrualResp.Order == "" || accrualResp.Accrual < 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := ach.accrualSvc.ApplyAccrual(r.Context(), &accrualResp); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, models.ErrAccrualStatusInvalid) {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, models.ErrOrderNotFound) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		logctx.FromContext(r.Context(), ach.logger).Error("failed to apply accrual callback", zap.String("order", accrualResp.Order), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/middleware"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"go.uber.org/zap"
+)
+
+const callbackBody = `{"order":"12345678903","status":"PROCESSED","accrual":500}`
+
+type fakeCallbackService struct {
+	applied []models.AccrualResp
+}
+
+func (s *fakeCallbackService) ApplyAccrual(_ context.Context, accrualResp *models.AccrualResp) error {
+	s.applied = append(s.applied, *accrualResp)
+	return nil
+}
+
+func TestAccrualCallbackSignature(t *testing.T) {
+	secret := []byte("webhook-secret")
+
+	tests := []struct {
+		name     string
+		body     string
+		ts       time.Time
+		sign     func(ts time.Time) string
+		wantCode int
+	}{
+		{
+			name:     "valid",
+			body:     callbackBody,
+			ts:       time.Now(),
+			sign:     func(ts time.Time) string { return middleware.Sign(secret, ts, []byte(callbackBody)) },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "tampered body",
+			body:     strings.Replace(callbackBody, "500", "5000", 1),
+			ts:       time.Now(),
+			sign:     func(ts time.Time) string { return middleware.Sign(secret, ts, []byte(callbackBody)) },
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "wrong secret",
+			body:     callbackBody,
+			ts:       time.Now(),
+			sign:     func(ts time.Time) string { return middleware.Sign([]byte("other"), ts, []byte(callbackBody)) },
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "stale timestamp",
+			body:     callbackBody,
+			ts:       time.Now().Add(-10 * time.Minute),
+			sign:     func(ts time.Time) string { return middleware.Sign(secret, ts, []byte(callbackBody)) },
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "future timestamp",
+			body:     callbackBody,
+			ts:       time.Now().Add(10 * time.Minute),
+			sign:     func(ts time.Time) string { return middleware.Sign(secret, ts, []byte(callbackBody)) },
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "replayed signature with fresh timestamp",
+			body: callbackBody,
+			ts:   time.Now(),
+			sign: func(time.Time) string {
+				return middleware.Sign(secret, time.Now().Add(-10*time.Minute), []byte(callbackBody))
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "missing signature",
+			body:     callbackBody,
+			ts:       time.Now(),
+			sign:     func(time.Time) string { return "" },
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeCallbackService{}
+			router := NewRouter(zap.NewNop(), nil, nil, nil, nil, nil, nil, nil, nil, nil,
+				NewAccrualCallbackHandler(svc, zap.NewNop()), nil, secret, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/internal/accrual/callback", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(middleware.TimestampHeader, strconv.FormatInt(tt.ts.Unix(), 10))
+			if sig := tt.sign(tt.ts); sig != "" {
+				req.Header.Set(middleware.SignatureHeader, sig)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("got %d, want %d", rec.Code, tt.wantCode)
+			}
+			wantApplied := 0
+			if tt.wantCode == http.StatusOK {
+				wantApplied = 1
+			}
+			if len(svc.applied) != wantApplied {
+				t.Fatalf("accrual applied %d times, want %d", len(svc.applied), wantApplied)
+			}
+			if wantApplied == 1 && (svc.applied[0].Order != "12345678903" || svc.applied[0].Accrual != 500) {
+				t.Fatalf("applied %+v", svc.applied[0])
+			}
+		})
+	}
+}
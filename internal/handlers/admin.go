@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/middleware"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+type AdminService interface {
+	SearchUsers(ctx context.Context, actorID int64, login string) ([]models.UserInfo, error)
+	ListUserOrders(ctx context.Context, actorID, userID int64) ([]models.Order, error)
+	ListUserWithdrawals(ctx context.Context, actorID, userID int64) ([]models.Withdrawal, error)
+	ListParkedOrders(ctx context.Context, actorID int64) ([]models.ParkedOrder, error)
+	ListScheduledTasks(ctx context.Context) ([]models.ScheduledTask, error)
+	RepollOrder(ctx context.Context, actorID int64, number string) error
+	InvalidateOrder(ctx context.Context, actorID int64, number string) error
+	AdjustBalance(ctx context.Context, actorID, userID int64, adj *models.AdjustmentReq) error
+	ListDiscrepancies(ctx context.Context, actorID int64, status string) ([]models.Discrepancy, error)
+	CorrectDiscrepancy(ctx context.Context, actorID, id int64) error
+	DismissDiscrepancy(ctx context.Context, actorID, id int64) error
+}
+
+type AdminHandler struct {
+	adminSvc AdminService
+	logger   *zap.Logger
+}
+
+func NewAdminHandler(adminSvc AdminService, logger *zap.Logger) *AdminHandler {
+	return &AdminHandler{
+		adminSvc: adminSvc,
+		logger:   logger.With(zap.String("handler", "admin")),
+	}
+}
+
+func (adh *AdminHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	login := strings.TrimSpace(r.URL.Query().Get("login"))
+	if login == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	users, err := adh.adminSvc.SearchUsers(r.Context(), actorID, login)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		logctx.FromContext(r.Context(), adh.logger).Error("failed to search users", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if len(users) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(users); err != nil {
+		logctx.FromContext(r.Context(), adh.logger).Error("failed to encode users", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (adh *AdminHandler) ListUserOrders(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID <= 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	orders, err := adh.adminSvc.ListUserOrders(r.Context(), actorID, userID)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, models.ErrUserNotFound) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		logctx.FromContext(r.Context(), adh.logger).Error("failed to list user orders", zap.Int64("user_id", userID), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if len(orders) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(orders); err != nil {
+		logctx.FromContext(r.Context(), adh.logger).Error("failed to encode orders", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (adh *AdminHandler) ListUserWithdrawals(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID <= 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	withdrawals, err := adh.adminSvc.ListUserWithdrawals(r.Context(), actorID, userID)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, models.ErrUserNotFound) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		logctx.FromContext(r.Context(), adh.logger).Error("failed to list user withdrawals", zap.Int64("user_id", userID), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if len(withdrawals) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(withdrawals); err != nil {
+		logctx.FromContext(r.Context(), adh.logger).Error("failed to encode withdrawals", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (adh *AdminHandler) ListParkedOrders(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	orders, err := adh.adminSvc.ListParkedOrders(r.Context(), actorID)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		logctx.FromContext(r.Context(), adh.logger).Error("failed to list parked orders", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if len(orders) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(orders); err != nil {
+		logctx.FromContext(r.Context(), adh.logger).Error("failed to encode parked orders", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (adh *AdminHandler) ListScheduledTasks(w http.ResponseWriter, r *http.Request) {
+	tasks, err := adh.adminSvc.ListScheduledTasks(r.Context())
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		logctx.FromContext(r.Context(), adh.logger).Error("failed to list scheduled tasks", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if len(tasks) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(tasks); err != nil {
+		logctx.FromContext(r.Context(), adh.logger).Error("failed to encode scheduled tasks", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (adh *AdminHandler) RepollOrder(w http.ResponseWriter, r *http.Request) {
+	adh.changeOrder(w, r, adh.adminSvc.RepollOrder, "failed to repoll order")
+}
+
+func (adh *AdminHandler) InvalidateOrder(w http.ResponseWriter, r *http.Request) {
+	adh.changeOrder(w, r, adh.adminSvc.InvalidateOrder, "failed to invalidate order")
+}
+
+func (adh *AdminHandler) changeOrder(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, actorID int64, number string) error, errMsg string) {
+	actorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	number := chi.URLParam(r, "number")
+	if number == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := fn(r.Context(), actorID, number); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, models.ErrOrderNotFound) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrOrderAlreadyProcessed) {
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+			return
+		}
+		logctx.FromContext(r.Context(), adh.logger).Error(errMsg, zap.String("order", number), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (adh *AdminHandler) AdjustBalance(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID <= 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var adj models.AdjustmentReq
+	if err = json.NewDecoder(r.Body).Decode(&adj); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err = adh.adminSvc.AdjustBalance(r.Context(), actorID, userID, &adj); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, models.ErrAdjustmentInvalid) {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, models.ErrUserNotFound) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrAdjustmentExceedsBalance) {
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+			return
+		}
+		logctx.FromContext(r.Context(), adh.logger).Error("failed to adjust balance", zap.Int64("user_id", userID), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (adh *AdminHandler) ListDiscrepancies(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	status := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("status")))
+	switch status {
+	case "", models.DiscrepancyOpen, models.DiscrepancyCorrected, models.DiscrepancyDismissed:
+	default:
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	discrepancies, err := adh.adminSvc.ListDiscrepancies(r.Context(), actorID, status)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		logctx.FromContext(r.Context(), adh.logger).Error("failed to list discrepancies", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if len(discrepancies) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(discrepancies); err != nil {
+		logctx.FromContext(r.Context(), adh.logger).Error("failed to encode discrepancies", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (adh *AdminHandler) CorrectDiscrepancy(w http.ResponseWriter, r *http.Request) {
+	adh.resolveDiscrepancy(w, r, adh.adminSvc.CorrectDiscrepancy, "failed to correct discrepancy")
+}
+
+func (adh *AdminHandler) DismissDiscrepancy(w http.ResponseWriter, r *http.Request) {
+	adh.resolveDiscrepancy(w, r, adh.adminSvc.DismissDiscrepancy, "failed to dismiss discrepancy")
+}
+
+func (adh *AdminHandler) resolveDiscrepancy(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, actorID, id int64) error, errMsg string) {
+	actorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "discrepancyID"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err = fn(r.Context(), actorID, id); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, models.ErrDiscrepancyNotFound) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrDiscrepancyResolved) {
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+			return
+		}
+		logctx.FromContext(r.Context(), adh.logger).Error(errMsg, zap.Int64("discrepancy_id", id), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
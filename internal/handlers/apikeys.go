@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/middleware"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+type APIKeysService interface {
+	CreateKey(ctx context.Context, actorID int64, req *models.APIKeyReq) (*models.APIKeyCreated, error)
+	UpdateKey(ctx context.Context, actorID, id int64, req *models.APIKeyReq) (*models.APIKey, error)
+	RevokeKey(ctx context.Context, actorID, id int64) error
+	GetKey(ctx context.Context, id int64) (*models.APIKey, error)
+	ListKeys(ctx context.Context) ([]models.APIKey, error)
+}
+
+type APIKeysHandler struct {
+	keysSvc APIKeysService
+	logger  *zap.Logger
+}
+
+func NewAPIKeysHandler(keysSvc APIKeysService, logger *zap.Logger) *APIKeysHandler {
+	return &APIKeysHandler{
+		keysSvc: keysSvc,
+		logger:  logger.With(zap.String("handler", "api_keys")),
+	}
+}
+
+func (kh *APIKeysHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	req, ok := decodeAPIKeyReq(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	key, err := kh.keysSvc.CreateKey(r.Context(), actorID, req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, models.ErrAPIKeyReqInvalid) {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		logctx.FromContext(r.Context(), kh.logger).Error("failed to create api key", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = json.NewEncoder(w).Encode(key); err != nil {
+		logctx.FromContext(r.Context(), kh.logger).Error("failed to encode api key", zap.Error(err))
+	}
+}
+
+func (kh *APIKeysHandler) UpdateKey(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "keyID"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	req, ok := decodeAPIKeyReq(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	key, err := kh.keysSvc.UpdateKey(r.Context(), actorID, id, req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, models.ErrAPIKeyReqInvalid) {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		logctx.FromContext(r.Context(), kh.logger).Error("failed to update api key", zap.Int64("api_key_id", id), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(key); err != nil {
+		logctx.FromContext(r.Context(), kh.logger).Error("failed to encode api key", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (kh *APIKeysHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "keyID"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err = kh.keysSvc.RevokeKey(r.Context(), actorID, id); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		logctx.FromContext(r.Context(), kh.logger).Error("failed to revoke api key", zap.Int64("api_key_id", id), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (kh *APIKeysHandler) GetKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "keyID"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	key, err := kh.keysSvc.GetKey(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		logctx.FromContext(r.Context(), kh.logger).Error("failed to get api key", zap.Int64("api_key_id", id), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(key); err != nil {
+		logctx.FromContext(r.Context(), kh.logger).Error("failed to encode api key", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (kh *APIKeysHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := kh.keysSvc.ListKeys(r.Context())
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		logctx.FromContext(r.Context(), kh.logger).Error("failed to list api keys", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if len(keys) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(keys); err != nil {
+		logctx.FromContext(r.Context(), kh.logger).Error("failed to encode api keys", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+func decodeAPIKeyReq(r *http.Request) (*models.APIKeyReq, bool) {
+	if !strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+		return nil, false
+	}
+
+	var req models.APIKeyReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, false
+	}
+	return &req, true
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
 	"go.uber.org/zap"
 )
@@ -58,7 +59,7 @@ func (ah *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
 			return
 		}
-		ah.logger.Error("failed to register user", zap.Error(err))
+		logctx.FromContext(r.Context(), ah.logger).Error("failed to register user", zap.Error(err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -97,7 +98,7 @@ func (ah *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 			return
 		}
-		ah.logger.Error("failed to authenticate user", zap.Error(err))
+		logctx.FromContext(r.Context(), ah.logger).Error("failed to authenticate user", zap.Error(err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
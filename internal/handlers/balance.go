@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/middleware"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/validate"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
@@ -48,14 +49,14 @@ func (bh *BalanceHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
 			return
 		}
-		bh.logger.Error("failed to get balance", zap.Error(err))
+		logctx.FromContext(r.Context(), bh.logger).Error("failed to get balance", zap.Error(err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err = json.NewEncoder(w).Encode(balance); err != nil {
-		bh.logger.Error("failed to encode balance", zap.Error(err))
+		logctx.FromContext(r.Context(), bh.logger).Error("failed to encode balance", zap.Error(err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -111,7 +112,7 @@ func (bh *BalanceHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
 			return
 		}
-		bh.logger.Error("failed to withdraw funds", zap.Error(err))
+		logctx.FromContext(r.Context(), bh.logger).Error("failed to withdraw funds", zap.Error(err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -135,7 +136,7 @@ func (bh *BalanceHandler) ListWithdrawals(w http.ResponseWriter, r *http.Request
 			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
 			return
 		}
-		bh.logger.Error("failed to list withdrawals", zap.Error(err))
+		logctx.FromContext(r.Context(), bh.logger).Error("failed to list withdrawals", zap.Error(err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -147,7 +148,7 @@ func (bh *BalanceHandler) ListWithdrawals(w http.ResponseWriter, r *http.Request
 
 	w.Header().Set("Content-Type", "application/json")
 	if err = json.NewEncoder(w).Encode(withdrawals); err != nil {
-		bh.logger.Error("failed to encode withdrawals", zap.Error(err))
+		logctx.FromContext(r.Context(), bh.logger).Error("failed to encode withdrawals", zap.Error(err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/middleware"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type logLevelPayload struct {
+	Level string `json:"level"`
+}
+
+type LogLevelAuditor interface {
+	RecordLogLevelChange(ctx context.Context, actorID int64, from, to string) error
+}
+
+type LogLevelHandler struct {
+	level   zap.AtomicLevel
+	auditor LogLevelAuditor
+	logger  *zap.Logger
+}
+
+func NewLogLevelHandler(level zap.AtomicLevel, auditor LogLevelAuditor, logger *zap.Logger) *LogLevelHandler {
+	return &LogLevelHandler{
+		level:   level,
+		auditor: auditor,
+		logger:  logger.With(zap.String("handler", "log_level")),
+	}
+}
+
+func (lh *LogLevelHandler) GetLevel(w http.ResponseWriter, r *http.Request) {
+	lh.write(w, r)
+}
+
+func (lh *LogLevelHandler) SetLevel(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var req logLevelPayload
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	lvl, err := zapcore.ParseLevel(req.Level)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	prev := lh.level.Level()
+	if err = lh.auditor.RecordLogLevelChange(r.Context(), actorID, prev.String(), lvl.String()); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		logctx.FromContext(r.Context(), lh.logger).Error("failed to audit log level change", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	lh.level.SetLevel(lvl)
+	logctx.FromContext(r.Context(), lh.logger).Warn("log level changed",
+		zap.Int64("actor_id", actorID),
+		zap.Stringer("from", prev),
+		zap.Stringer("to", lvl),
+	)
+
+	lh.write(w, r)
+}
+
+func (lh *LogLevelHandler) write(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(logLevelPayload{Level: lh.level.String()}); err != nil {
+		logctx.FromContext(r.Context(), lh.logger).Error("failed to encode log level", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/middleware"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type adminToken struct{}
+
+func (adminToken) Validate(string) (*models.Principal, error) {
+	return &models.Principal{UserID: 7, Role: models.RoleAdmin}, nil
+}
+
+type fakeAuditor struct {
+	err     error
+	records [][3]any
+}
+
+func (a *fakeAuditor) RecordLogLevelChange(_ context.Context, actorID int64, from, to string) error {
+	if a.err != nil {
+		return a.err
+	}
+	a.records = append(a.records, [3]any{actorID, from, to})
+	return nil
+}
+
+func setLevel(t *testing.T, h *LogLevelHandler, body string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/admin/log/level", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: "token"})
+	rec := httptest.NewRecorder()
+	middleware.Auth(zap.NewNop(), adminToken{}, nil)(http.HandlerFunc(h.SetLevel)).ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestSetLogLevelIsAudited(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	auditor := &fakeAuditor{}
+	h := NewLogLevelHandler(level, auditor, zap.NewNop())
+
+	if code := setLevel(t, h, `{"level":"debug"}`); code != http.StatusOK {
+		t.Fatalf("got %d, want 200", code)
+	}
+	if level.Level() != zapcore.DebugLevel {
+		t.Fatalf("level is %s, want debug", level.Level())
+	}
+	if len(auditor.records) != 1 || auditor.records[0] != [3]any{int64(7), "info", "debug"} {
+		t.Fatalf("audit records %v", auditor.records)
+	}
+}
+
+func TestSetLogLevelKeepsLevelWhenAuditFails(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	h := NewLogLevelHandler(level, &fakeAuditor{err: errors.New("db down")}, zap.NewNop())
+
+	if code := setLevel(t, h, `{"level":"debug"}`); code != http.StatusInternalServerError {
+		t.Fatalf("got %d, want 500", code)
+	}
+	if level.Level() != zapcore.InfoLevel {
+		t.Fatalf("level changed to %s without an audit record", level.Level())
+	}
+}
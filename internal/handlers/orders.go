@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/middleware"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/validate"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
@@ -15,7 +16,7 @@ import (
 )
 
 type OrdersService interface {
-	LoadOrder(ctx context.Context, userID int64, num string) error
+	LoadOrder(ctx context.Context, userID, apiKeyID int64, num string) error
 	ListOrders(ctx context.Context, userID int64) ([]models.Order, error)
 }
 
@@ -32,7 +33,7 @@ func NewOrdersHandler(ordersSvc OrdersService, logger *zap.Logger) *OrdersHandle
 }
 
 func (oh *OrdersHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
-	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	principal, ok := middleware.GetPrincipalFromContext(r.Context())
 	if !ok {
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
@@ -56,7 +57,7 @@ func (oh *OrdersHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = oh.ordersSvc.LoadOrder(r.Context(), userID, number); err != nil {
+	if err = oh.ordersSvc.LoadOrder(r.Context(), principal.UserID, principal.APIKeyID, number); err != nil {
 		if errors.Is(err, context.Canceled) {
 			return
 		}
@@ -72,7 +73,7 @@ func (oh *OrdersHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusConflict)
 			return
 		}
-		oh.logger.Error("failed to load order", zap.Error(err))
+		logctx.FromContext(r.Context(), oh.logger).Error("failed to load order", zap.Error(err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -94,7 +95,7 @@ func (oh *OrdersHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
 			return
 		}
-		oh.logger.Error("failed to get orders", zap.Error(err))
+		logctx.FromContext(r.Context(), oh.logger).Error("failed to get orders", zap.Error(err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -106,7 +107,7 @@ func (oh *OrdersHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	if err = json.NewEncoder(w).Encode(orders); err != nil {
-		oh.logger.Error("failed to encode orders", zap.Error(err))
+		logctx.FromContext(r.Context(), oh.logger).Error("failed to encode orders", zap.Error(err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -1,30 +1,76 @@
 package handlers
 
 import (
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/health"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/jwtmanager"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/metrics"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/middleware"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
-func NewRouter(logger *zap.Logger, validator *jwtmanager.JWTManager, ah *AuthHandler, oh *OrdersHandler, bh *BalanceHandler) *chi.Mux {
+func NewRouter(logger *zap.Logger, validator *jwtmanager.JWTManager, keyAuth middleware.KeyAuthenticator, ah *AuthHandler, oh *OrdersHandler, bh *BalanceHandler, th *TransactionsHandler, sh *StatementHandler, adh *AdminHandler, kh *APIKeysHandler, ach *AccrualCallbackHandler, llh *LogLevelHandler, callbackSecret []byte, hc *health.Checker) *chi.Mux {
 	r := chi.NewRouter()
+	r.Use(middleware.Tracing("/metrics", "/healthz", "/readyz"))
+	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger(logger))
 	r.Use(middleware.Compress(logger))
 
+	r.Handle("/metrics", metrics.Handler())
+	r.Get("/healthz", hc.Live)
+	r.Get("/readyz", hc.Ready)
+
 	r.Route("/api/user", func(r chi.Router) {
 		r.Post("/register", ah.Register)
 		r.Post("/login", ah.Login)
 
 		r.Group(func(r chi.Router) {
-			r.Use(middleware.Auth(logger, validator))
-			r.Post("/orders", oh.CreateOrder)
-			r.Get("/orders", oh.GetOrders)
-			r.Get("/balance", bh.GetBalance)
-			r.Post("/balance/withdraw", bh.Withdraw)
-			r.Get("/withdrawals", bh.ListWithdrawals)
+			r.Use(middleware.Auth(logger, validator, keyAuth))
+			r.With(middleware.RequireScope(logger, models.ScopeOrdersWrite)).Post("/orders", oh.CreateOrder)
+			r.With(middleware.RequireScope(logger, models.ScopeOrdersRead)).Get("/orders", oh.GetOrders)
+			r.With(middleware.RequireScope(logger, models.ScopeBalanceRead)).Get("/balance", bh.GetBalance)
+			r.With(middleware.RequireScope(logger, models.ScopeBalanceWrite)).Post("/balance/withdraw", bh.Withdraw)
+			r.With(middleware.RequireScope(logger, models.ScopeBalanceRead)).Get("/withdrawals", bh.ListWithdrawals)
+			r.With(middleware.RequireScope(logger, models.ScopeBalanceRead)).Get("/transactions", th.ListTransactions)
+			r.With(middleware.RequireScope(logger, models.ScopeBalanceRead)).Get("/statement", sh.GetStatement)
 		})
 	})
 
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(middleware.Auth(logger, validator, keyAuth))
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireRole(logger, models.RoleSupport, models.RoleAdmin))
+			r.Get("/users", adh.SearchUsers)
+			r.Get("/users/{userID}/orders", adh.ListUserOrders)
+			r.Get("/users/{userID}/withdrawals", adh.ListUserWithdrawals)
+			r.Get("/orders/parked", adh.ListParkedOrders)
+			r.Get("/reconciliation/discrepancies", adh.ListDiscrepancies)
+			r.Get("/scheduler/tasks", adh.ListScheduledTasks)
+			r.Get("/log/level", llh.GetLevel)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireRole(logger, models.RoleAdmin))
+			r.Post("/users/{userID}/adjustments", adh.AdjustBalance)
+			r.Post("/orders/{number}/repoll", adh.RepollOrder)
+			r.Post("/orders/{number}/invalidate", adh.InvalidateOrder)
+			r.Post("/reconciliation/discrepancies/{discrepancyID}/correct", adh.CorrectDiscrepancy)
+			r.Post("/reconciliation/discrepancies/{discrepancyID}/dismiss", adh.DismissDiscrepancy)
+			r.Put("/log/level", llh.SetLevel)
+
+			r.Get("/api-keys", kh.ListKeys)
+			r.Post("/api-keys", kh.CreateKey)
+			r.Get("/api-keys/{keyID}", kh.GetKey)
+			r.Put("/api-keys/{keyID}", kh.UpdateKey)
+			r.Delete("/api-keys/{keyID}", kh.RevokeKey)
+		})
+	})
+
+	if len(callbackSecret) > 0 {
+		r.With(middleware.VerifySignature(logger, callbackSecret)).Post("/api/internal/accrual/callback", ach.Callback)
+	}
+
 	return r
 }
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/middleware"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/pdf"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/services"
+	"go.uber.org/zap"
+)
+
+const statementDateLayout = "2006-01-02"
+
+type StatementService interface {
+	GenerateStatement(ctx context.Context, userID int64, from, to time.Time, sw services.StatementWriter) error
+}
+
+type StatementHandler struct {
+	statementSvc StatementService
+	logger       *zap.Logger
+}
+
+func NewStatementHandler(statementSvc StatementService, logger *zap.Logger) *StatementHandler {
+	return &StatementHandler{
+		statementSvc: statementSvc,
+		logger:       logger.With(zap.String("handler", "statement")),
+	}
+}
+
+func (sh *StatementHandler) GetStatement(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	from, to, err := parseStatementPeriod(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var sw statementWriter
+	switch r.URL.Query().Get("format") {
+	case "", "csv":
+		sw = &csvStatementWriter{w: w}
+	case "pdf":
+		sw = &pdfStatementWriter{w: w}
+	default:
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err = sh.statementSvc.GenerateStatement(r.Context(), userID, from, to, sw); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if sw.Started() {
+			logctx.FromContext(r.Context(), sh.logger).Error("failed to stream statement", zap.Error(err))
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		logctx.FromContext(r.Context(), sh.logger).Error("failed to generate statement", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+func parseStatementPeriod(r *http.Request) (time.Time, time.Time, error) {
+	q := r.URL.Query()
+
+	from, err := time.Parse(statementDateLayout, q.Get("from"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %w", err)
+	}
+	to, err := time.Parse(statementDateLayout, q.Get("to"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %w", err)
+	}
+	to = to.AddDate(0, 0, 1)
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from must not be after to")
+	}
+	return from, to, nil
+}
+
+type statementWriter interface {
+	services.StatementWriter
+	Started() bool
+}
+
+type csvStatementWriter struct {
+	w  http.ResponseWriter
+	cw *csv.Writer
+}
+
+func (sw *csvStatementWriter) Started() bool {
+	return sw.cw != nil
+}
+
+func (sw *csvStatementWriter) WriteOpening(from, to time.Time, balance float64) error {
+	sw.w.Header().Set("Content-Type", "text/csv")
+	sw.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="statement_%s_%s.csv"`,
+		from.Format(statementDateLayout), to.AddDate(0, 0, -1).Format(statementDateLayout)))
+	sw.w.WriteHeader(http.StatusOK)
+
+	sw.cw = csv.NewWriter(sw.w)
+	if err := sw.cw.Write([]string{"type", "order", "description", "amount", "balance", "created_at"}); err != nil {
+		return err
+	}
+	return sw.cw.Write([]string{"OPENING_BALANCE", "", "", "", formatAmount(balance), from.Format(time.RFC3339)})
+}
+
+func (sw *csvStatementWriter) WriteEntry(t *models.Transaction) error {
+	return sw.cw.Write([]string{t.Type, t.Order, t.Description, formatAmount(t.Amount), formatAmount(t.Balance), t.CreatedAt.Format(time.RFC3339)})
+}
+
+func (sw *csvStatementWriter) WriteClosing(balance float64) error {
+	if err := sw.cw.Write([]string{"CLOSING_BALANCE", "", "", "", formatAmount(balance), ""}); err != nil {
+		return err
+	}
+	sw.cw.Flush()
+	return sw.cw.Error()
+}
+
+type pdfStatementWriter struct {
+	w  http.ResponseWriter
+	pw *pdf.Writer
+}
+
+func (sw *pdfStatementWriter) Started() bool {
+	return sw.pw != nil
+}
+
+func (sw *pdfStatementWriter) WriteOpening(from, to time.Time, balance float64) error {
+	sw.w.Header().Set("Content-Type", "application/pdf")
+	sw.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="statement_%s_%s.pdf"`,
+		from.Format(statementDateLayout), to.AddDate(0, 0, -1).Format(statementDateLayout)))
+	sw.w.WriteHeader(http.StatusOK)
+
+	sw.pw = pdf.NewWriter(sw.w)
+	if err := sw.pw.Heading(fmt.Sprintf("Statement %s - %s",
+		from.Format(statementDateLayout), to.AddDate(0, 0, -1).Format(statementDateLayout))); err != nil {
+		return err
+	}
+	return sw.pw.Heading("Opening balance: " + formatAmount(balance))
+}
+
+func (sw *pdfStatementWriter) WriteEntry(t *models.Transaction) error {
+	line := fmt.Sprintf("%s  %-10s  %-20s  %12s  %12s", t.CreatedAt.Format("2006-01-02 15:04"), t.Type, t.Order, formatAmount(t.Amount), formatAmount(t.Balance))
+	if t.Description != "" {
+		line += "  " + t.Description
+	}
+	return sw.pw.Text(line)
+}
+
+func (sw *pdfStatementWriter) WriteClosing(balance float64) error {
+	if err := sw.pw.Heading("Closing balance: " + formatAmount(balance)); err != nil {
+		return err
+	}
+	return sw.pw.Close()
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/middleware"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+type TransactionsService interface {
+	ListTransactions(ctx context.Context, userID int64, limit, offset int) ([]models.Transaction, error)
+}
+
+type TransactionsHandler struct {
+	transactionsSvc TransactionsService
+	logger          *zap.Logger
+}
+
+func NewTransactionsHandler(transactionsSvc TransactionsService, logger *zap.Logger) *TransactionsHandler {
+	return &TransactionsHandler{
+		transactionsSvc: transactionsSvc,
+		logger:          logger.With(zap.String("handler", "transactions")),
+	}
+}
+
+func (th *TransactionsHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	transactions, err := th.transactionsSvc.ListTransactions(r.Context(), userID, limit, offset)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+			return
+		}
+		logctx.FromContext(r.Context(), th.logger).Error("failed to list transactions", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if len(transactions) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="transactions.csv"`)
+		if err = writeTransactionsCSV(w, transactions); err != nil {
+			logctx.FromContext(r.Context(), th.logger).Error("failed to encode transactions to csv", zap.Error(err))
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(transactions); err != nil {
+		logctx.FromContext(r.Context(), th.logger).Error("failed to encode transactions", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+func parsePagination(r *http.Request) (int, int, error) {
+	limit, offset := defaultPageLimit, 0
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxPageLimit {
+			return 0, 0, errors.New("invalid limit")
+		}
+		limit = n
+	}
+
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("invalid offset")
+		}
+		offset = n
+	}
+
+	return limit, offset, nil
+}
+
+func writeTransactionsCSV(w http.ResponseWriter, transactions []models.Transaction) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"type", "order", "description", "amount", "balance", "created_at"}); err != nil {
+		return err
+	}
+	for _, t := range transactions {
+		record := []string{
+			t.Type,
+			t.Order,
+			t.Description,
+			formatAmount(t.Amount),
+			formatAmount(t.Balance),
+			t.CreatedAt.Format(time.RFC3339),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
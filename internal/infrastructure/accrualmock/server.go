@@ -0,0 +1,302 @@
+package accrualmock
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	StatusNotRegistered = "NOT_REGISTERED"
+	StatusTooMany       = "TOO_MANY"
+	StatusError         = "ERROR"
+)
+
+type Rule struct {
+	Number    string   `json:"number"`
+	Prefix    string   `json:"prefix"`
+	Statuses  []string `json:"statuses"`
+	Accrual   float64  `json:"accrual"`
+	LatencyMS int      `json:"latency_ms"`
+	ErrorRate float64  `json:"error_rate"`
+}
+
+type Config struct {
+	Default    Rule   `json:"default"`
+	Rules      []Rule `json:"rules"`
+	RateLimit  int    `json:"rate_limit"`
+	RetryAfter int    `json:"retry_after"`
+
+	DisableBatch bool `json:"disable_batch"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Default: Rule{
+			Statuses: []string{models.StatusRegistered, models.StatusProcessing, models.StatusProcessed},
+			Accrual:  100,
+		},
+		RetryAfter: 60,
+	}
+}
+
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read mock config %q: %w", path, err)
+	}
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse mock config %q: %w", path, err)
+	}
+	return cfg, nil
+}
+
+type Server struct {
+	mu       sync.Mutex
+	cfg      Config
+	requests map[string]int
+	window   time.Time
+	inWindow int
+}
+
+func New(cfg Config) *Server {
+	return &Server{
+		cfg:      cfg,
+		requests: make(map[string]int),
+	}
+}
+
+func (s *Server) SetConfig(cfg Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg = cfg
+	s.requests = make(map[string]int)
+	s.window, s.inWindow = time.Time{}, 0
+}
+
+func (s *Server) Requests(number string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[number]
+}
+
+func (s *Server) Handler() *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/api/orders/{number}", s.getOrder)
+	r.Post("/api/orders/batch", s.getOrders)
+	return r
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	lim := s.admit()
+	if lim.limited {
+		writeTooMany(w, lim)
+		return
+	}
+
+	rule, status := s.peek(number)
+	transient := status != StatusTooMany && status != StatusError && rule.ErrorRate > 0 && rand.Float64() < rule.ErrorRate
+	if !transient {
+		rule, status = s.step(number)
+	}
+	if !sleep(r, rule.LatencyMS) {
+		return
+	}
+
+	switch {
+	case status == StatusTooMany:
+		writeTooMany(w, lim)
+	case transient, status == StatusError:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	case status == StatusNotRegistered, status == "":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(accrualResp(number, rule, status))
+	}
+}
+
+func (s *Server) getOrders(w http.ResponseWriter, r *http.Request) {
+	lim := s.admit()
+	if lim.batchDisabled {
+		http.NotFound(w, r)
+		return
+	}
+	if lim.limited {
+		writeTooMany(w, lim)
+		return
+	}
+
+	var numbers []string
+	if err := json.NewDecoder(r.Body).Decode(&numbers); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		results  []models.AccrualResp
+		latency  int
+		failed   bool
+		limited  bool
+		consumed []string
+	)
+	for _, number := range numbers {
+		rule, status := s.peek(number)
+		latency = max(latency, rule.LatencyMS)
+
+		switch {
+		case status == StatusTooMany:
+			limited = true
+			consumed = append(consumed, number)
+		case status == StatusError:
+			failed = true
+			consumed = append(consumed, number)
+		case rule.ErrorRate > 0 && rand.Float64() < rule.ErrorRate:
+			failed = true
+		case status == StatusNotRegistered, status == "":
+		default:
+			results = append(results, accrualResp(number, rule, status))
+		}
+	}
+
+	if !sleep(r, latency) {
+		return
+	}
+
+	switch {
+	case limited:
+		s.advance(consumed...)
+		writeTooMany(w, lim)
+	case failed:
+		s.advance(consumed...)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	case len(results) == 0:
+		s.advance(numbers...)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.advance(numbers...)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(results)
+	}
+}
+
+type limits struct {
+	limited       bool
+	batchDisabled bool
+	rateLimit     int
+	retryAfter    int
+}
+
+func (s *Server) admit() limits {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lim := limits{
+		batchDisabled: s.cfg.DisableBatch,
+		rateLimit:     s.cfg.RateLimit,
+		retryAfter:    s.cfg.RetryAfter,
+	}
+
+	if s.cfg.RateLimit > 0 {
+		now := time.Now()
+		if now.Sub(s.window) >= time.Minute {
+			s.window, s.inWindow = now, 0
+		}
+		s.inWindow++
+		lim.limited = s.inWindow > s.cfg.RateLimit
+	}
+	return lim
+}
+
+func (s *Server) step(number string) (Rule, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, status := s.status(number)
+	s.requests[number]++
+	return rule, status
+}
+
+func (s *Server) peek(number string) (Rule, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status(number)
+}
+
+func (s *Server) advance(numbers ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, number := range numbers {
+		s.requests[number]++
+	}
+}
+
+func (s *Server) status(number string) (Rule, string) {
+	rule := s.match(number)
+	if len(rule.Statuses) == 0 {
+		return rule, ""
+	}
+	return rule, rule.Statuses[min(s.requests[number], len(rule.Statuses)-1)]
+}
+
+func (s *Server) match(number string) Rule {
+	best, bestLen := s.cfg.Default, -1
+	for _, rule := range s.cfg.Rules {
+		if rule.Number != "" {
+			if rule.Number == number {
+				return rule
+			}
+			continue
+		}
+		if len(rule.Prefix) > bestLen && strings.HasPrefix(number, rule.Prefix) {
+			best, bestLen = rule, len(rule.Prefix)
+		}
+	}
+	return best
+}
+
+func accrualResp(number string, rule Rule, status string) models.AccrualResp {
+	resp := models.AccrualResp{
+		Order:  number,
+		Status: status,
+	}
+	if status == models.StatusProcessed {
+		resp.Accrual = rule.Accrual
+	}
+	return resp
+}
+
+func writeTooMany(w http.ResponseWriter, lim limits) {
+	w.Header().Set("Retry-After", strconv.Itoa(lim.retryAfter))
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusTooManyRequests)
+	if lim.rateLimit > 0 {
+		fmt.Fprintf(w, "No more than %d requests per minute allowed", lim.rateLimit)
+	}
+}
+
+func sleep(r *http.Request, ms int) bool {
+	if ms <= 0 {
+		return true
+	}
+	select {
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
@@ -0,0 +1,141 @@
+package accrualmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+)
+
+func postBatch(t *testing.T, srv *httptest.Server, numbers ...string) (int, []models.AccrualResp) {
+	t.Helper()
+
+	body, _ := json.Marshal(numbers)
+	resp, err := http.Post(srv.URL+"/api/orders/batch", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("post batch: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var results []models.AccrualResp
+	if resp.StatusCode == http.StatusOK {
+		if err = json.NewDecoder(resp.Body).Decode(&results); err != nil {
+			t.Fatalf("decode batch: %v", err)
+		}
+	}
+	return resp.StatusCode, results
+}
+
+func getOrderStatus(t *testing.T, srv *httptest.Server, number string) int {
+	t.Helper()
+
+	resp, err := http.Get(srv.URL + "/api/orders/" + number)
+	if err != nil {
+		t.Fatalf("get order: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestBatchAdvancesOnlyOnSuccess(t *testing.T) {
+	tests := []struct {
+		name     string
+		failing  []string
+		wantCode int
+	}{
+		{"server error", []string{StatusError, models.StatusProcessed}, http.StatusInternalServerError},
+		{"too many requests", []string{StatusTooMany, models.StatusProcessed}, http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.RetryAfter = 0
+			cfg.Rules = []Rule{{Number: "2", Statuses: tt.failing, Accrual: 10}}
+			s := New(cfg)
+			srv := httptest.NewServer(s.Handler())
+			defer srv.Close()
+
+			if code, _ := postBatch(t, srv, "1", "2", "3"); code != tt.wantCode {
+				t.Fatalf("first batch: got %d, want %d", code, tt.wantCode)
+			}
+			for number, want := range map[string]int{"1": 0, "2": 1, "3": 0} {
+				if got := s.Requests(number); got != want {
+					t.Errorf("order %s advanced %d times, want %d", number, got, want)
+				}
+			}
+
+			code, results := postBatch(t, srv, "1", "2", "3")
+			if code != http.StatusOK {
+				t.Fatalf("second batch: got %d", code)
+			}
+			got := make(map[string]string, len(results))
+			for _, r := range results {
+				got[r.Order] = r.Status
+			}
+			want := map[string]string{"1": models.StatusRegistered, "2": models.StatusProcessed, "3": models.StatusRegistered}
+			for number, status := range want {
+				if got[number] != status {
+					t.Errorf("order %s: got %q, want %q", number, got[number], status)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchNoContentAdvances(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Default.Statuses = []string{StatusNotRegistered, models.StatusRegistered}
+	s := New(cfg)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	if code, _ := postBatch(t, srv, "1", "2"); code != http.StatusNoContent {
+		t.Fatalf("got %d, want 204", code)
+	}
+	if code, results := postBatch(t, srv, "1", "2"); code != http.StatusOK || len(results) != 2 {
+		t.Fatalf("got %d with %d results, want 200 with 2", code, len(results))
+	}
+}
+
+func TestDisabledBatchFallsBackToSingle(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DisableBatch = true
+	s := New(cfg)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	if code, _ := postBatch(t, srv, "1"); code != http.StatusNotFound {
+		t.Fatalf("batch: got %d, want 404", code)
+	}
+	if got := s.Requests("1"); got != 0 {
+		t.Fatalf("disabled batch advanced order %d times", got)
+	}
+	if code := getOrderStatus(t, srv, "1"); code != http.StatusOK {
+		t.Fatalf("single: got %d, want 200", code)
+	}
+	if got := s.Requests("1"); got != 1 {
+		t.Fatalf("single request advanced order %d times, want 1", got)
+	}
+}
+
+func TestRandomErrorsDoNotAdvance(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Rules = []Rule{{Number: "1", Statuses: []string{models.StatusProcessed}, Accrual: 10, ErrorRate: 1}}
+	s := New(cfg)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	if code := getOrderStatus(t, srv, "1"); code != http.StatusInternalServerError {
+		t.Fatalf("single: got %d, want 500", code)
+	}
+	if code, _ := postBatch(t, srv, "1"); code != http.StatusInternalServerError {
+		t.Fatalf("batch: got %d, want 500", code)
+	}
+	if got := s.Requests("1"); got != 0 {
+		t.Fatalf("random failures advanced the sequence %d times, want 0", got)
+	}
+}
@@ -0,0 +1,124 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const testOpenTimeout = 20 * time.Millisecond
+
+type step struct {
+	op        string
+	wantAllow bool
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	tests := []struct {
+		name      string
+		steps     []step
+		wantState State
+		wantReady bool
+	}{
+		{
+			name:      "stays closed below threshold",
+			steps:     []step{{op: "failure"}, {op: "failure"}, {op: "allow", wantAllow: true}},
+			wantState: StateClosed,
+			wantReady: true,
+		},
+		{
+			name:      "success resets failure count",
+			steps:     []step{{op: "failure"}, {op: "failure"}, {op: "success"}, {op: "failure"}, {op: "failure"}},
+			wantState: StateClosed,
+			wantReady: true,
+		},
+		{
+			name:      "opens at threshold",
+			steps:     []step{{op: "failure"}, {op: "failure"}, {op: "failure"}, {op: "allow", wantAllow: false}},
+			wantState: StateOpen,
+			wantReady: false,
+		},
+		{
+			name: "half-open after timeout admits a single probe",
+			steps: []step{
+				{op: "failure"}, {op: "failure"}, {op: "failure"}, {op: "wait"},
+				{op: "allow", wantAllow: true}, {op: "allow", wantAllow: false},
+			},
+			wantState: StateHalfOpen,
+			wantReady: false,
+		},
+		{
+			name: "successful probe closes",
+			steps: []step{
+				{op: "failure"}, {op: "failure"}, {op: "failure"}, {op: "wait"},
+				{op: "allow", wantAllow: true}, {op: "success"}, {op: "allow", wantAllow: true},
+			},
+			wantState: StateClosed,
+			wantReady: true,
+		},
+		{
+			name: "failed probe reopens",
+			steps: []step{
+				{op: "failure"}, {op: "failure"}, {op: "failure"}, {op: "wait"},
+				{op: "allow", wantAllow: true}, {op: "failure"}, {op: "allow", wantAllow: false},
+			},
+			wantState: StateOpen,
+			wantReady: false,
+		},
+		{
+			name: "aborted probe frees the slot",
+			steps: []step{
+				{op: "failure"}, {op: "failure"}, {op: "failure"}, {op: "wait"},
+				{op: "allow", wantAllow: true}, {op: "abort"}, {op: "allow", wantAllow: true},
+			},
+			wantState: StateHalfOpen,
+			wantReady: false,
+		},
+		{
+			name: "aborted probe leaves breaker ready",
+			steps: []step{
+				{op: "failure"}, {op: "failure"}, {op: "failure"}, {op: "wait"},
+				{op: "allow", wantAllow: true}, {op: "abort"},
+			},
+			wantState: StateHalfOpen,
+			wantReady: true,
+		},
+		{
+			name:      "open breaker is ready once timeout elapses",
+			steps:     []step{{op: "failure"}, {op: "failure"}, {op: "failure"}, {op: "wait"}},
+			wantState: StateOpen,
+			wantReady: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New("test", 3, testOpenTimeout, zap.NewNop())
+			for i, s := range tt.steps {
+				switch s.op {
+				case "allow":
+					if got := b.Allow(); got != s.wantAllow {
+						t.Fatalf("step %d: Allow() = %v, want %v (state %s)", i, got, s.wantAllow, b.State())
+					}
+				case "success":
+					b.Success()
+				case "failure":
+					b.Failure()
+				case "abort":
+					b.Abort()
+				case "wait":
+					time.Sleep(testOpenTimeout + 5*time.Millisecond)
+				default:
+					t.Fatalf("unknown op %q", s.op)
+				}
+			}
+			if got := b.State(); got != tt.wantState {
+				t.Errorf("state = %s, want %s", got, tt.wantState)
+			}
+			if got := b.Ready(); got != tt.wantReady {
+				t.Errorf("Ready() = %v, want %v", got, tt.wantReady)
+			}
+		})
+	}
+}
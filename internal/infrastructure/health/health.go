@@ -0,0 +1,97 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type Check func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   Check
+}
+
+type report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type Checker struct {
+	checks   []check
+	timeout  time.Duration
+	draining atomic.Bool
+	logger   *zap.Logger
+}
+
+func NewChecker(timeout time.Duration, logger *zap.Logger) *Checker {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &Checker{
+		timeout: timeout,
+		logger:  logger.With(zap.String("handler", "health")),
+	}
+}
+
+func (c *Checker) Add(name string, fn Check) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+func (c *Checker) Live(w http.ResponseWriter, _ *http.Request) {
+	c.write(w, http.StatusOK, &report{Status: "ok"})
+}
+
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	if c.draining.Load() {
+		c.write(w, http.StatusServiceUnavailable, &report{Status: "draining"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), c.timeout)
+	defer cancel()
+
+	results := make([]error, len(c.checks))
+	var wg sync.WaitGroup
+	for i, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = ch.fn(ctx)
+		}()
+	}
+	wg.Wait()
+
+	rep := &report{Status: "ok", Checks: make(map[string]string, len(c.checks))}
+	status := http.StatusOK
+	for i, ch := range c.checks {
+		if err := results[i]; err != nil {
+			rep.Checks[ch.name] = err.Error()
+			rep.Status = "fail"
+			status = http.StatusServiceUnavailable
+			c.logger.Warn("readiness check failed", zap.String("check", ch.name), zap.Error(err))
+			continue
+		}
+		rep.Checks[ch.name] = "ok"
+	}
+	c.write(w, status, rep)
+}
+
+func (c *Checker) write(w http.ResponseWriter, status int, rep *report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(rep); err != nil {
+		c.logger.Error("failed to encode health report", zap.Error(err))
+	}
+}
@@ -2,46 +2,168 @@ package httpclient
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/breaker"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/metrics"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
 	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
+const batchReprobeInterval = 10 * time.Minute
+
+var rateLimitRe = regexp.MustCompile(`No more than (\d+) requests per minute allowed`)
+
 type AccrualClient struct {
-	client *resty.Client
+	client       *resty.Client
+	cb           *breaker.Breaker
+	batchSize    int
+	batchRetryAt atomic.Int64
 }
 
-func NewAccrualClient(addr string) *AccrualClient {
+func NewAccrualClient(name, addr string, cb *breaker.Breaker, batchSize int) *AccrualClient {
 	return &AccrualClient{
 		client: resty.New().
 			SetBaseURL(addr).
-			SetTimeout(5 * time.Second),
+			SetTransport(otelhttp.NewTransport(http.DefaultTransport)).
+			SetTimeout(5 * time.Second).
+			OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+				observe(name, resp.Request, strconv.Itoa(resp.StatusCode()), resp.Time())
+				return nil
+			}).
+			OnError(func(req *resty.Request, err error) {
+				code := "error"
+				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+					code = "timeout"
+				}
+				observe(name, req, code, time.Since(req.Time))
+			}),
+		cb:        cb,
+		batchSize: batchSize,
+	}
+}
+
+func observe(provider string, req *resty.Request, code string, d time.Duration) {
+	endpoint := "order"
+	if req.Method == http.MethodPost {
+		endpoint = "batch"
+	}
+	metrics.AccrualRequests.WithLabelValues(provider, endpoint, code).Inc()
+	metrics.AccrualDuration.WithLabelValues(provider, endpoint).Observe(d.Seconds())
+}
+
+func (c *AccrualClient) Ready() bool {
+	return c.cb.Ready()
+}
+
+func (c *AccrualClient) BatchSize() int {
+	if time.Now().UnixNano() < c.batchRetryAt.Load() {
+		return 0
+	}
+	return c.batchSize
+}
+
+func (c *AccrualClient) GetOrderAccrual(ctx context.Context, number string) (*models.AccrualResp, error) {
+	if !c.cb.Allow() {
+		return nil, models.ErrAccrualUnavailable
+	}
+
+	accrualResp, err := c.getOrderAccrual(ctx, number)
+	c.record(ctx, err)
+	return accrualResp, err
+}
+
+func (c *AccrualClient) GetOrdersAccrual(ctx context.Context, numbers []string) (map[string]*models.AccrualResp, error) {
+	if c.BatchSize() <= 0 {
+		return nil, models.ErrAccrualBatchUnsupported
 	}
+	if !c.cb.Allow() {
+		return nil, models.ErrAccrualUnavailable
+	}
+
+	results, err := c.getOrdersAccrual(ctx, numbers)
+	if errors.Is(err, models.ErrAccrualBatchUnsupported) {
+		c.batchRetryAt.Store(time.Now().Add(batchReprobeInterval).UnixNano())
+	}
+	c.record(ctx, err)
+	return results, err
 }
 
-func (c *AccrualClient) GetOrderAccrual(ctx context.Context, number string) (*models.AccrualResp, time.Duration, error) {
+func (c *AccrualClient) record(ctx context.Context, err error) {
+	switch {
+	case err == nil,
+		errors.Is(err, models.ErrAccrualOrderNotRegistered),
+		errors.Is(err, models.ErrAccrualOrderTooMany),
+		errors.Is(err, models.ErrAccrualBatchUnsupported):
+		c.cb.Success()
+	case ctx.Err() != nil:
+		c.cb.Abort()
+	default:
+		c.cb.Failure()
+	}
+}
+
+func (c *AccrualClient) getOrdersAccrual(ctx context.Context, numbers []string) (map[string]*models.AccrualResp, error) {
+	var accrualOrders []models.AccrualResp
+	resp, err := c.client.R().
+		SetContext(ctx).
+		SetBody(numbers).
+		SetResult(&accrualOrders).
+		Post("/api/orders/batch")
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusOK:
+		results := make(map[string]*models.AccrualResp, len(accrualOrders))
+		for i := range accrualOrders {
+			results[accrualOrders[i].Order] = &accrualOrders[i]
+		}
+		return results, nil
+	case http.StatusNoContent:
+		return map[string]*models.AccrualResp{}, nil
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, models.ErrAccrualBatchUnsupported
+	case http.StatusTooManyRequests:
+		return nil, &models.AccrualThrottledError{
+			RetryAfter:     parseRetryAfter(resp.Header().Get("Retry-After")),
+			LimitPerMinute: parseRateLimit(resp.String()),
+		}
+	default:
+		return nil, fmt.Errorf("accrual unexpected status code: %d %s", resp.StatusCode(), resp.Status())
+	}
+}
+
+func (c *AccrualClient) getOrderAccrual(ctx context.Context, number string) (*models.AccrualResp, error) {
 	var accrualOrder models.AccrualResp
 	resp, err := c.client.R().
 		SetContext(ctx).
 		SetResult(&accrualOrder).
 		Get(fmt.Sprintf("/api/orders/%s", number))
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
 	switch resp.StatusCode() {
 	case http.StatusOK:
-		return &accrualOrder, 0, nil
+		return &accrualOrder, nil
 	case http.StatusNoContent:
-		return nil, 0, models.ErrAccrualOrderNotRegistered
+		return nil, models.ErrAccrualOrderNotRegistered
 	case http.StatusTooManyRequests:
-		retry := parseRetryAfter(resp.Header().Get("Retry-After"))
-		return nil, retry, models.ErrAccrualOrderTooMany
+		return nil, &models.AccrualThrottledError{
+			RetryAfter:     parseRetryAfter(resp.Header().Get("Retry-After")),
+			LimitPerMinute: parseRateLimit(resp.String()),
+		}
 	default:
-		return nil, 0, fmt.Errorf("accrual unexpected status code: %d %s", resp.StatusCode(), resp.Status())
+		return nil, fmt.Errorf("accrual unexpected status code: %d %s", resp.StatusCode(), resp.Status())
 
 	}
 }
@@ -63,6 +185,18 @@ func parseRetryAfter(h string) time.Duration {
 	return 0
 }
 
+func parseRateLimit(body string) int {
+	m := rateLimitRe.FindStringSubmatch(body)
+	if m == nil {
+		return 0
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return n
+}
+
 func httpTime(s string) (time.Time, error) {
 	layouts := []string{
 		time.RFC1123,
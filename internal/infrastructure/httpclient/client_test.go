@@ -0,0 +1,186 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/breaker"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/tracing"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestAccrualClientPropagatesTraceContext(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := tracing.Install(exp, "test")
+	defer tp.Shutdown(context.Background())
+
+	headers := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(models.AccrualResp{Order: "12345678903", Status: models.StatusProcessed, Accrual: 10})
+	}))
+	defer srv.Close()
+
+	client := NewAccrualClient("test", srv.URL, breaker.New("test", 5, time.Second, zap.NewNop()), 0)
+
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	if _, err := client.GetOrderAccrual(ctx, "12345678903"); err != nil {
+		t.Fatalf("get order accrual: %v", err)
+	}
+	parent.End()
+
+	h := <-headers
+	if h.Get("traceparent") == "" {
+		t.Fatal("traceparent header is missing")
+	}
+	remote := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), propagation.HeaderCarrier(h)))
+	if remote.TraceID() != parent.SpanContext().TraceID() {
+		t.Errorf("trace id %s, want %s", remote.TraceID(), parent.SpanContext().TraceID())
+	}
+
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	var clientSpan *tracetest.SpanStub
+	spans := exp.GetSpans()
+	for i := range spans {
+		if spans[i].SpanKind == trace.SpanKindClient {
+			clientSpan = &spans[i]
+		}
+	}
+	if clientSpan == nil {
+		t.Fatal("no client span exported")
+	}
+	if clientSpan.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("client span parent %s, want %s", clientSpan.Parent.SpanID(), parent.SpanContext().SpanID())
+	}
+	if remote.SpanID() != clientSpan.SpanContext.SpanID() {
+		t.Errorf("traceparent span id %s, want client span %s", remote.SpanID(), clientSpan.SpanContext.SpanID())
+	}
+}
+
+func TestAccrualClientReprobesBatchAfterCooldown(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	client := NewAccrualClient("test", srv.URL, breaker.New("test", 5, time.Second, zap.NewNop()), 10)
+
+	if _, err := client.GetOrdersAccrual(context.Background(), []string{"12345678903"}); !errors.Is(err, models.ErrAccrualBatchUnsupported) {
+		t.Fatalf("got %v, want ErrAccrualBatchUnsupported", err)
+	}
+	if got := client.BatchSize(); got != 0 {
+		t.Fatalf("batch size right after unsupported = %d, want 0", got)
+	}
+
+	client.batchRetryAt.Store(time.Now().Add(-time.Millisecond).UnixNano())
+	if got := client.BatchSize(); got != 10 {
+		t.Fatalf("batch size after cooldown = %d, want 10", got)
+	}
+}
+
+func TestAccrualClientBreakerSuppressesPolling(t *testing.T) {
+	var (
+		status   atomic.Int32
+		requests atomic.Int32
+	)
+	status.Store(http.StatusInternalServerError)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		if code := int(status.Load()); code != http.StatusOK {
+			w.WriteHeader(code)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(models.AccrualResp{Order: "12345678903", Status: models.StatusProcessed, Accrual: 10})
+	}))
+	defer srv.Close()
+
+	const openTimeout = 30 * time.Millisecond
+	cb := breaker.New("test", 2, openTimeout, zap.NewNop())
+	client := NewAccrualClient("test", srv.URL, cb, 0)
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := client.GetOrderAccrual(ctx, "12345678903"); err == nil {
+			t.Fatal("expected server error")
+		}
+	}
+	if cb.State() != breaker.StateOpen || client.Ready() {
+		t.Fatalf("breaker is %s after 2 failures, want open", cb.State())
+	}
+
+	if _, err := client.GetOrderAccrual(ctx, "12345678903"); !errors.Is(err, models.ErrAccrualUnavailable) {
+		t.Fatalf("got %v while open, want ErrAccrualUnavailable", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Fatalf("server saw %d requests, want 2: open breaker must not send requests", got)
+	}
+
+	status.Store(http.StatusOK)
+	time.Sleep(openTimeout + 10*time.Millisecond)
+	if !client.Ready() {
+		t.Fatal("client not ready after open timeout")
+	}
+	if _, err := client.GetOrderAccrual(ctx, "12345678903"); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if cb.State() != breaker.StateClosed {
+		t.Fatalf("breaker is %s after successful probe, want closed", cb.State())
+	}
+	if _, err := client.GetOrderAccrual(ctx, "12345678903"); err != nil {
+		t.Fatalf("poll after probe: %v", err)
+	}
+	if got := requests.Load(); got != 4 {
+		t.Fatalf("server saw %d requests, want 4", got)
+	}
+}
+
+func TestAccrualClientBreakerIgnoresNonFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		batch   bool
+		wantErr error
+	}{
+		{"not registered", http.StatusNoContent, false, models.ErrAccrualOrderNotRegistered},
+		{"throttled", http.StatusTooManyRequests, false, nil},
+		{"batch unsupported", http.StatusNotFound, true, models.ErrAccrualBatchUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			cb := breaker.New("test", 1, time.Minute, zap.NewNop())
+			client := NewAccrualClient("test", srv.URL, cb, 10)
+
+			var err error
+			if tt.batch {
+				_, err = client.GetOrdersAccrual(context.Background(), []string{"12345678903"})
+			} else {
+				_, err = client.GetOrderAccrual(context.Background(), "12345678903")
+			}
+			var throttled *models.AccrualThrottledError
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) || tt.wantErr == nil && !errors.As(err, &throttled) {
+				t.Fatalf("got %v", err)
+			}
+			if cb.State() != breaker.StateClosed {
+				t.Fatalf("breaker is %s, want closed", cb.State())
+			}
+		})
+	}
+}
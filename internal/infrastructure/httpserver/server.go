@@ -10,7 +10,26 @@ import (
 	"go.uber.org/zap"
 )
 
-func StartServer(ctx context.Context, addr string, r *chi.Mux, logger *zap.Logger) error {
+type Option func(*options)
+
+type options struct {
+	drainDelay time.Duration
+	onDrain    func()
+}
+
+func WithDrain(delay time.Duration, onDrain func()) Option {
+	return func(o *options) {
+		o.drainDelay = delay
+		o.onDrain = onDrain
+	}
+}
+
+func StartServer(ctx context.Context, addr string, r *chi.Mux, logger *zap.Logger, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	srv := &http.Server{
 		Addr:    addr,
 		Handler: r,
@@ -32,6 +51,18 @@ func StartServer(ctx context.Context, addr string, r *chi.Mux, logger *zap.Logge
 
 	select {
 	case <-ctx.Done():
+		if o.onDrain != nil {
+			o.onDrain()
+		}
+		if o.drainDelay > 0 {
+			logger.Info("server is draining...", zap.Duration("delay", o.drainDelay))
+			select {
+			case <-time.After(o.drainDelay):
+			case err := <-errCh:
+				return err
+			}
+		}
+
 		logger.Info("server is shutting down...")
 
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -0,0 +1,342 @@
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/tracing"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/worker"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/zap"
+)
+
+const storeTimeout = 5 * time.Second
+
+type Store interface {
+	EnqueueJob(ctx context.Context, job *models.Job) (bool, error)
+	ClaimJobs(ctx context.Context, owner string, types []string, limit int, ttl time.Duration) ([]models.Job, error)
+	ExtendJobLease(ctx context.Context, owner string, id int64, ttl time.Duration) error
+	CompleteJob(ctx context.Context, owner string, id int64) error
+	RetryJob(ctx context.Context, owner string, id int64, runAt time.Time, lastErr string) error
+	BuryJob(ctx context.Context, owner string, id int64, lastErr string) error
+	ReleaseJob(ctx context.Context, owner string, id int64) error
+}
+
+type Handler func(ctx context.Context, job *models.Job) error
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+type Leader interface {
+	IsLeader() bool
+}
+
+type Options struct {
+	Owner        string
+	Leader       Leader
+	Workers      int
+	PollInterval time.Duration
+	LeaseTTL     time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	DrainTimeout time.Duration
+}
+
+type Periodic struct {
+	Type      string
+	Key       string
+	Every     time.Duration
+	Priority  int
+	Singleton bool
+	Local     bool
+}
+
+type Queue struct {
+	store    Store
+	logger   *zap.Logger
+	opts     Options
+	pool     *worker.WorkerPool
+	handlers map[string]Handler
+	periodic []Periodic
+}
+
+func New(store Store, logger *zap.Logger, opts Options) *Queue {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.LeaseTTL <= 0 {
+		opts.LeaseTTL = 5 * time.Minute
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Minute
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = 10 * time.Second
+	}
+	return &Queue{
+		store:    store,
+		logger:   logger.With(zap.String("owner", opts.Owner)),
+		opts:     opts,
+		pool:     worker.NewWorkerPool(opts.Workers, 0),
+		handlers: make(map[string]Handler),
+	}
+}
+
+func (q *Queue) Register(jobType string, h Handler) {
+	q.handlers[jobType] = h
+}
+
+func (q *Queue) Schedule(p Periodic) {
+	q.periodic = append(q.periodic, p)
+}
+
+func (q *Queue) Resize(workers int) {
+	q.pool.Resize(workers)
+}
+
+func (q *Queue) Stats() worker.Stats {
+	return q.pool.Stats()
+}
+
+func (q *Queue) Enqueue(ctx context.Context, jobType, key string, payload any, priority int, runAt time.Time) (bool, error) {
+	return q.enqueue(ctx, jobType, key, "", payload, priority, runAt)
+}
+
+func (q *Queue) EnqueueLocal(ctx context.Context, jobType, key string, payload any, priority int, runAt time.Time) (bool, error) {
+	return q.enqueue(ctx, jobType, key, q.opts.Owner, payload, priority, runAt)
+}
+
+func (q *Queue) enqueue(ctx context.Context, jobType, key, owner string, payload any, priority int, runAt time.Time) (bool, error) {
+	var raw json.RawMessage
+	if payload != nil {
+		var err error
+		raw, err = json.Marshal(payload)
+		if err != nil {
+			return false, fmt.Errorf("failed to encode job payload: %w", err)
+		}
+	}
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+
+	return q.store.EnqueueJob(ctx, &models.Job{
+		Type:        jobType,
+		Key:         key,
+		Owner:       owner,
+		Payload:     raw,
+		Priority:    priority,
+		MaxAttempts: q.opts.MaxAttempts,
+		RunAt:       runAt,
+	})
+}
+
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, p := range q.periodic {
+		wg.Add(1)
+		go func(p Periodic) {
+			defer wg.Done()
+			q.runPeriodic(ctx, p)
+		}(p)
+	}
+
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	types := make([]string, 0, len(q.handlers))
+	for t := range q.handlers {
+		types = append(types, t)
+	}
+
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+
+	for ctx.Err() == nil {
+		stats := q.pool.Stats()
+		free := stats.Workers - stats.Busy - stats.Queued
+		if free > 0 {
+			jobs, err := q.store.ClaimJobs(ctx, q.opts.Owner, types, free, q.opts.LeaseTTL)
+			if err != nil && ctx.Err() == nil {
+				q.logger.Warn("failed to claim jobs", zap.Error(err))
+			}
+			for i := range jobs {
+				q.dispatch(ctx, jobCtx, &wg, &jobs[i])
+			}
+			if len(jobs) == free {
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+
+	q.logger.Info("draining job queue", zap.Int("in_flight", q.pool.Stats().Busy))
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		q.pool.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(q.opts.DrainTimeout):
+		q.logger.Warn("job queue drain timed out, cancelling in-flight jobs")
+		cancelJobs()
+		<-done
+	}
+	q.logger.Info("job queue stopped")
+}
+
+func (q *Queue) dispatch(ctx, jobCtx context.Context, wg *sync.WaitGroup, job *models.Job) {
+	h := q.handlers[job.Type]
+	result, err := q.pool.Submit(jobCtx, func(ctx context.Context) error {
+		ctx, span := tracing.Start(ctx, "job "+job.Type,
+			attribute.Int64("job.id", job.ID),
+			attribute.Int("job.attempt", job.Attempts),
+		)
+		defer span.End()
+
+		ctx = logctx.WithFields(ctx, zap.Int64("job_id", job.ID), zap.String("job_type", job.Type))
+		stop := q.startLeaseHeartbeat(ctx, job)
+		err := h(ctx, job)
+		stop()
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	})
+	if err != nil {
+		q.finish(ctx, jobCtx, job, err)
+		return
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.finish(ctx, jobCtx, job, <-result)
+	}()
+}
+
+func (q *Queue) startLeaseHeartbeat(ctx context.Context, job *models.Job) func() {
+	hbCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(q.opts.LeaseTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-hbCtx.Done():
+				return
+			case <-ticker.C:
+				extCtx, extCancel := context.WithTimeout(hbCtx, storeTimeout)
+				err := q.store.ExtendJobLease(extCtx, q.opts.Owner, job.ID, q.opts.LeaseTTL)
+				extCancel()
+				if err != nil && hbCtx.Err() == nil {
+					logctx.FromContext(hbCtx, q.logger).Warn("failed to extend job lease", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (q *Queue) finish(ctx, jobCtx context.Context, job *models.Job, err error) {
+	log := q.logger.With(zap.Int64("job_id", job.ID), zap.String("job_type", job.Type), zap.Int("attempt", job.Attempts))
+
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+	defer cancel()
+
+	var (
+		permanent *permanentError
+		panicked  *worker.PanicError
+	)
+	switch {
+	case err == nil:
+		err = q.store.CompleteJob(storeCtx, q.opts.Owner, job.ID)
+	case jobCtx.Err() != nil || errors.Is(err, worker.ErrPoolStopped):
+		log.Info("job interrupted by shutdown, releasing")
+		err = q.store.ReleaseJob(storeCtx, q.opts.Owner, job.ID)
+	case errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts:
+		log.Error("job failed permanently", zap.Error(err))
+		err = q.store.BuryJob(storeCtx, q.opts.Owner, job.ID, err.Error())
+	default:
+		if errors.As(err, &panicked) {
+			log.Error("job panicked", zap.Any("panic", panicked.Value), zap.ByteString("stack", panicked.Stack))
+		}
+		delay := q.backoff(job.Attempts)
+		log.Warn("job failed, retrying", zap.Duration("retry_in", delay), zap.Error(err))
+		err = q.store.RetryJob(storeCtx, q.opts.Owner, job.ID, time.Now().Add(delay), err.Error())
+	}
+
+	if err != nil {
+		log.Error("failed to update job state", zap.Error(err))
+	}
+}
+
+func (q *Queue) runPeriodic(ctx context.Context, p Periodic) {
+	ticker := time.NewTicker(p.Every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if p.Singleton && q.opts.Leader != nil && !q.opts.Leader.IsLeader() {
+				continue
+			}
+			enqueue := q.Enqueue
+			if p.Local {
+				enqueue = q.EnqueueLocal
+			}
+			if _, err := enqueue(ctx, p.Type, p.Key, nil, p.Priority, time.Time{}); err != nil && ctx.Err() == nil {
+				q.logger.Warn("failed to enqueue periodic job", zap.String("job_type", p.Type), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.opts.BaseBackoff
+	for i := 1; i < attempts && d < q.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, q.opts.MaxBackoff)
+}
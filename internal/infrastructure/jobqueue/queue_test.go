@@ -0,0 +1,165 @@
+package jobqueue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"go.uber.org/zap"
+)
+
+const testLeaseTTL = 60 * time.Millisecond
+
+type fakeStore struct {
+	mu        sync.Mutex
+	pending   []models.Job
+	extended  map[int64]int
+	completed map[int64]bool
+	enqueued  []models.Job
+}
+
+func newFakeStore(jobs ...models.Job) *fakeStore {
+	return &fakeStore{pending: jobs, extended: make(map[int64]int), completed: make(map[int64]bool)}
+}
+
+func (s *fakeStore) EnqueueJob(_ context.Context, job *models.Job) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enqueued = append(s.enqueued, *job)
+	return true, nil
+}
+
+func (s *fakeStore) ClaimJobs(_ context.Context, _ string, _ []string, limit int, _ time.Duration) ([]models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(limit, len(s.pending))
+	jobs := s.pending[:n]
+	s.pending = s.pending[n:]
+	return jobs, nil
+}
+
+func (s *fakeStore) ExtendJobLease(_ context.Context, _ string, id int64, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.completed[id] {
+		return models.ErrJobLeaseLost
+	}
+	s.extended[id]++
+	return nil
+}
+
+func (s *fakeStore) CompleteJob(_ context.Context, _ string, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.completed[id] = true
+	return nil
+}
+
+func (s *fakeStore) RetryJob(context.Context, string, int64, time.Time, string) error { return nil }
+func (s *fakeStore) BuryJob(context.Context, string, int64, string) error             { return nil }
+func (s *fakeStore) ReleaseJob(context.Context, string, int64) error                  { return nil }
+
+func (s *fakeStore) enqueuedOwners() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	owners := make(map[string]string)
+	for _, job := range s.enqueued {
+		owners[job.Type] = job.Owner
+	}
+	return owners
+}
+
+func (s *fakeStore) state(id int64) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.extended[id], s.completed[id]
+}
+
+func runQueue(t *testing.T, store *fakeStore, register func(q *Queue)) {
+	t.Helper()
+
+	q := New(store, zap.NewNop(), Options{Owner: "test", Workers: 2, PollInterval: 5 * time.Millisecond, LeaseTTL: testLeaseTTL})
+	register(q)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestQueueExtendsLeaseWhileJobRuns(t *testing.T) {
+	store := newFakeStore(models.Job{ID: 1, Type: "slow", MaxAttempts: 1}, models.Job{ID: 2, Type: "fast", MaxAttempts: 1})
+	finished := make(chan struct{})
+	runQueue(t, store, func(q *Queue) {
+		q.Register("slow", func(ctx context.Context, _ *models.Job) error {
+			defer close(finished)
+			select {
+			case <-time.After(4 * testLeaseTTL):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		q.Register("fast", func(context.Context, *models.Job) error { return nil })
+	})
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("slow job did not finish")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, done := store.state(1); done || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	extended, completed := store.state(1)
+	if !completed {
+		t.Fatal("slow job was not completed")
+	}
+	if extended < 3 {
+		t.Fatalf("lease of a job running for 4 TTLs was extended %d times", extended)
+	}
+
+	time.Sleep(2 * testLeaseTTL)
+	if after, _ := store.state(1); after != extended {
+		t.Fatalf("lease was extended %d more times after the job finished", after-extended)
+	}
+	if extended, _ := store.state(2); extended != 0 {
+		t.Fatalf("lease of a short job was extended %d times", extended)
+	}
+}
+
+func TestQueueEnqueuesLocalPeriodicForOwner(t *testing.T) {
+	store := newFakeStore()
+	runQueue(t, store, func(q *Queue) {
+		q.Schedule(Periodic{Type: "local", Key: "test", Every: 5 * time.Millisecond, Local: true})
+		q.Schedule(Periodic{Type: "shared", Key: "shared", Every: 5 * time.Millisecond})
+	})
+
+	deadline := time.Now().Add(time.Second)
+	owners := store.enqueuedOwners()
+	for len(owners) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		owners = store.enqueuedOwners()
+	}
+
+	if owner, ok := owners["local"]; !ok || owner != "test" {
+		t.Errorf("local periodic job owner = %q (enqueued %v), want %q", owner, ok, "test")
+	}
+	if owner, ok := owners["shared"]; !ok || owner != "" {
+		t.Errorf("shared periodic job owner = %q (enqueued %v), want none", owner, ok)
+	}
+}
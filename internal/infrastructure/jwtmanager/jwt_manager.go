@@ -4,9 +4,15 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+type Claims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role,omitempty"`
+}
+
 type JWTManager struct {
 	secret string
 	ttl    time.Duration
@@ -19,13 +25,16 @@ func NewJWTManager(secret string, ttl time.Duration) *JWTManager {
 	}
 }
 
-func (m *JWTManager) Generate(id int64) (string, error) {
+func (m *JWTManager) Generate(id int64, role string) (string, error) {
 	now := time.Now().UTC()
-	claims := jwt.RegisteredClaims{
-		Subject:   strconv.FormatInt(id, 10),
-		IssuedAt:  jwt.NewNumericDate(now),
-		NotBefore: jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(now.Add(m.ttl)),
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatInt(id, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.ttl)),
+		},
+		Role: role,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -33,23 +42,31 @@ func (m *JWTManager) Generate(id int64) (string, error) {
 	return token.SignedString([]byte(m.secret))
 }
 
-func (m *JWTManager) Validate(tokenString string) (int64, error) {
-	var claims jwt.RegisteredClaims
+func (m *JWTManager) Validate(tokenString string) (*models.Principal, error) {
+	var claims Claims
 	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(m.secret), nil
 	})
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	if !token.Valid {
-		return 0, jwt.ErrSignatureInvalid
+		return nil, jwt.ErrSignatureInvalid
 	}
 
 	id, err := strconv.ParseInt(claims.Subject, 10, 64)
 	if err != nil || id <= 0 {
-		return 0, jwt.ErrTokenInvalidSubject
+		return nil, jwt.ErrTokenInvalidSubject
+	}
+
+	role := claims.Role
+	if role == "" {
+		role = models.RoleUser
 	}
 
-	return id, nil
+	return &models.Principal{
+		UserID: id,
+		Role:   role,
+	}, nil
 }
@@ -0,0 +1,139 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const resignTimeout = 5 * time.Second
+
+type Store interface {
+	AcquireLeadership(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	ResignLeadership(ctx context.Context, name, holder string) error
+}
+
+type Elector struct {
+	store  Store
+	name   string
+	holder string
+	ttl    time.Duration
+	logger *zap.Logger
+
+	leader     atomic.Bool
+	validUntil atomic.Int64
+
+	mu        sync.Mutex
+	onElected []func(ctx context.Context)
+	onRevoked []func()
+	cancel    context.CancelFunc
+}
+
+func New(store Store, name, holder string, ttl time.Duration, logger *zap.Logger) *Elector {
+	if ttl <= 0 {
+		ttl = 15 * time.Second
+	}
+	return &Elector{
+		store:  store,
+		name:   name,
+		holder: holder,
+		ttl:    ttl,
+		logger: logger.With(zap.String("election", name), zap.String("holder", holder)),
+	}
+}
+
+func (e *Elector) OnElected(fn func(ctx context.Context)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onElected = append(e.onElected, fn)
+}
+
+func (e *Elector) OnRevoked(fn func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onRevoked = append(e.onRevoked, fn)
+}
+
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load() && time.Now().UnixNano() < e.validUntil.Load()
+}
+
+func (e *Elector) Run(ctx context.Context) {
+	renewEvery := e.ttl / 3
+	ticker := time.NewTicker(renewEvery)
+	defer ticker.Stop()
+
+	var renewed time.Time
+	for {
+		started := time.Now()
+		renewCtx, cancel := context.WithTimeout(ctx, e.ttl-renewEvery)
+		ok, err := e.store.AcquireLeadership(renewCtx, e.name, e.holder, e.ttl)
+		timedOut := errors.Is(renewCtx.Err(), context.DeadlineExceeded)
+		cancel()
+
+		switch {
+		case ctx.Err() != nil:
+		case err != nil:
+			e.logger.Warn("failed to renew leadership", zap.Bool("timed_out", timedOut), zap.Error(err))
+			if e.leader.Load() && (timedOut || time.Since(renewed) > e.ttl-renewEvery) {
+				e.revoke()
+			}
+		case ok:
+			renewed = started
+			e.validUntil.Store(started.Add(e.ttl).UnixNano())
+			if !e.leader.Load() {
+				e.elect(ctx)
+			}
+		case e.leader.Load():
+			e.revoke()
+		}
+
+		select {
+		case <-ctx.Done():
+			if e.leader.Load() {
+				e.revoke()
+				resignCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), resignTimeout)
+				if err = e.store.ResignLeadership(resignCtx, e.name, e.holder); err != nil {
+					e.logger.Warn("failed to resign leadership", zap.Error(err))
+				}
+				cancel()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) elect(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	e.cancel = cancel
+	e.leader.Store(true)
+	e.logger.Info("leadership acquired")
+
+	for _, fn := range e.onElected {
+		go fn(leaderCtx)
+	}
+}
+
+func (e *Elector) revoke() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.leader.Store(false)
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+	}
+	e.logger.Info("leadership lost")
+
+	for _, fn := range e.onRevoked {
+		fn()
+	}
+}
@@ -0,0 +1,204 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const testTTL = 150 * time.Millisecond
+
+type fakeStore struct {
+	mu      sync.Mutex
+	holder  string
+	expires time.Time
+	stalled map[string]bool
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{stalled: make(map[string]bool)}
+}
+
+func (s *fakeStore) AcquireLeadership(ctx context.Context, _, holder string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	if s.stalled[holder] {
+		s.mu.Unlock()
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.holder != holder && now.Before(s.expires) {
+		return false, nil
+	}
+	s.holder = holder
+	s.expires = now.Add(ttl)
+	return true, nil
+}
+
+func (s *fakeStore) ResignLeadership(_ context.Context, _, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holder == holder {
+		s.holder = ""
+		s.expires = time.Time{}
+	}
+	return nil
+}
+
+func (s *fakeStore) stall(holder string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stalled[holder] = true
+}
+
+type cluster struct {
+	t        *testing.T
+	store    *fakeStore
+	electors []*Elector
+	cancels  []context.CancelFunc
+	wg       sync.WaitGroup
+	overlap  atomic.Bool
+	stop     chan struct{}
+}
+
+func startCluster(t *testing.T, n int, setup ...func(e *Elector)) *cluster {
+	t.Helper()
+
+	c := &cluster{t: t, store: newFakeStore(), stop: make(chan struct{})}
+	for i := range n {
+		e := New(c.store, "test", fmt.Sprintf("instance-%d", i), testTTL, zap.NewNop())
+		for _, fn := range setup {
+			fn(e)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		c.electors = append(c.electors, e)
+		c.cancels = append(c.cancels, cancel)
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			e.Run(ctx)
+		}()
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-time.After(time.Millisecond):
+				if len(c.leaders()) > 1 {
+					c.overlap.Store(true)
+				}
+			}
+		}
+	}()
+
+	t.Cleanup(func() {
+		for _, cancel := range c.cancels {
+			cancel()
+		}
+		close(c.stop)
+		c.wg.Wait()
+		if c.overlap.Load() {
+			t.Error("more than one instance held leadership at the same time")
+		}
+	})
+	return c
+}
+
+func (c *cluster) leaders() []int {
+	var ids []int
+	for i, e := range c.electors {
+		if e.IsLeader() {
+			ids = append(ids, i)
+		}
+	}
+	return ids
+}
+
+func (c *cluster) waitLeader(exclude int, within time.Duration) int {
+	c.t.Helper()
+	deadline := time.Now().Add(within)
+	for time.Now().Before(deadline) {
+		if ids := c.leaders(); len(ids) == 1 && ids[0] != exclude {
+			return ids[0]
+		}
+		time.Sleep(time.Millisecond)
+	}
+	c.t.Fatalf("no new leader within %s, leaders: %v", within, c.leaders())
+	return -1
+}
+
+func TestElectorSingleLeader(t *testing.T) {
+	c := startCluster(t, 5)
+	first := c.waitLeader(-1, testTTL)
+
+	time.Sleep(3 * testTTL)
+	if ids := c.leaders(); len(ids) != 1 || ids[0] != first {
+		t.Fatalf("leadership changed without failure: %d -> %v", first, ids)
+	}
+}
+
+func TestElectorHandsOverOnResign(t *testing.T) {
+	c := startCluster(t, 3)
+	first := c.waitLeader(-1, testTTL)
+
+	c.cancels[first]()
+	next := c.waitLeader(first, testTTL)
+	if next == first {
+		t.Fatalf("leadership stayed with %d", first)
+	}
+}
+
+func TestElectorHandsOverOnExpiry(t *testing.T) {
+	c := startCluster(t, 3)
+	first := c.waitLeader(-1, testTTL)
+
+	var revoked atomic.Bool
+	c.electors[first].OnRevoked(func() { revoked.Store(true) })
+	c.store.stall(c.electors[first].holder)
+
+	c.waitLeader(first, 3*testTTL)
+	if c.electors[first].IsLeader() {
+		t.Fatal("stalled instance still reports leadership")
+	}
+
+	deadline := time.Now().Add(testTTL)
+	for !revoked.Load() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !revoked.Load() {
+		t.Fatal("stalled instance was not revoked after renewal timeout")
+	}
+}
+
+func TestElectorOnElectedContextCancelledOnRevoke(t *testing.T) {
+	elected := make(chan context.Context, 1)
+	c := startCluster(t, 1, func(e *Elector) {
+		e.OnElected(func(ctx context.Context) { elected <- ctx })
+	})
+	c.waitLeader(-1, testTTL)
+
+	var leaderCtx context.Context
+	select {
+	case leaderCtx = <-elected:
+	case <-time.After(testTTL):
+		t.Fatal("OnElected was not called")
+	}
+
+	c.store.stall(c.electors[0].holder)
+	select {
+	case <-leaderCtx.Done():
+	case <-time.After(2 * testTTL):
+		t.Fatal("leader context was not cancelled after renewal timeout")
+	}
+}
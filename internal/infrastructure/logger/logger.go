@@ -2,24 +2,109 @@ package logger
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/configs"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-func NewLogger(cfg *configs.ServerConfig) (*zap.Logger, error) {
+const (
+	ModeDev  = "dev"
+	ModeProd = "prod"
+
+	samplingTick       = time.Second
+	samplingFirst      = 100
+	samplingThereafter = 100
+)
+
+func NewLogger(cfg *configs.ServerConfig) (*zap.Logger, zap.AtomicLevel, error) {
 	lvl, err := zap.ParseAtomicLevel(cfg.LogLevel)
 	if err != nil {
-		return nil, fmt.Errorf("invalid log level: %w", err)
+		return nil, lvl, fmt.Errorf("invalid log level: %w", err)
+	}
+
+	var (
+		encCfg     zapcore.EncoderConfig
+		stackLevel zapcore.Level
+		opts       []zap.Option
+	)
+	switch cfg.LogMode {
+	case ModeProd:
+		encCfg = zap.NewProductionEncoderConfig()
+		encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+		stackLevel = zapcore.ErrorLevel
+	default:
+		encCfg = zap.NewDevelopmentEncoderConfig()
+		stackLevel = zapcore.WarnLevel
+		opts = append(opts, zap.Development())
 	}
 
-	lConf := zap.NewDevelopmentConfig()
-	lConf.Level = lvl
+	var enc zapcore.Encoder
+	switch cfg.LogEncoding {
+	case "json":
+		enc = zapcore.NewJSONEncoder(encCfg)
+	case "console":
+		enc = zapcore.NewConsoleEncoder(encCfg)
+	default:
+		if cfg.LogMode == ModeProd {
+			enc = zapcore.NewJSONEncoder(encCfg)
+		} else {
+			enc = zapcore.NewConsoleEncoder(encCfg)
+		}
+	}
+
+	rules, err := parseRedactRules(cfg.LogRedact, []byte(cfg.LogHashKey))
+	if err != nil {
+		return nil, lvl, fmt.Errorf("invalid log redaction rules: %w", err)
+	}
 
-	zl, err := lConf.Build()
+	out, err := openOutputs(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build logger config: %w", err)
+		return nil, lvl, err
 	}
 
-	return zl, nil
+	core := newRedactCore(zapcore.NewCore(enc, out, lvl), rules)
+	if cfg.LogSampling {
+		core = zapcore.NewSamplerWithOptions(core, samplingTick, samplingFirst, samplingThereafter)
+	}
+
+	opts = append(opts,
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+		zap.AddCaller(),
+		zap.AddStacktrace(stackLevel),
+	)
+	return zap.New(core, opts...), lvl, nil
+}
+
+func NewFallbackLogger() *zap.Logger {
+	rules, _ := parseRedactRules(configs.DefaultLogRedact, nil)
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	core := newRedactCore(zapcore.NewCore(enc, zapcore.Lock(os.Stderr), zapcore.InfoLevel), rules)
+	return zap.New(core, zap.ErrorOutput(zapcore.Lock(os.Stderr)))
+}
+
+func openOutputs(cfg *configs.ServerConfig) (zapcore.WriteSyncer, error) {
+	var syncers []zapcore.WriteSyncer
+	for _, path := range strings.Split(cfg.LogOutput, ",") {
+		switch path = strings.TrimSpace(path); path {
+		case "":
+		case "stdout":
+			syncers = append(syncers, zapcore.Lock(os.Stdout))
+		case "stderr":
+			syncers = append(syncers, zapcore.Lock(os.Stderr))
+		default:
+			rf, err := newRotatingFile(path, int64(cfg.LogMaxSize)<<20, cfg.LogRotateInterval, cfg.LogMaxBackups)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open log output %q: %w", path, err)
+			}
+			syncers = append(syncers, rf)
+		}
+	}
+	if len(syncers) == 0 {
+		syncers = append(syncers, zapcore.Lock(os.Stderr))
+	}
+	return zapcore.NewMultiWriteSyncer(syncers...), nil
 }
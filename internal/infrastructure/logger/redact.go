@@ -0,0 +1,248 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	RedactNone = "none"
+
+	messageKey = "msg"
+
+	redactedValue = "[REDACTED]"
+	visibleDigits = 4
+	minDigitRun   = 8
+	hashLen       = 16
+)
+
+type redactor func(string) string
+
+var dsnPassword = regexp.MustCompile(`password\s*=\s*('(?:[^'\\]|\\.)*'|\S+)`)
+
+type redactRules map[string]redactor
+
+func parseRedactRules(spec string, hashKey []byte) (redactRules, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == RedactNone {
+		return nil, nil
+	}
+	if len(hashKey) == 0 {
+		hashKey = make([]byte, sha256.Size)
+		if _, err := rand.Read(hashKey); err != nil {
+			return nil, fmt.Errorf("failed to generate log hash key: %w", err)
+		}
+	}
+
+	rules := make(redactRules)
+	var queryKeys []string
+	for _, rule := range strings.Split(spec, ",") {
+		key, strategy, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		key, strategy = strings.TrimSpace(key), strings.TrimSpace(strategy)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid redaction rule %q: must be key=strategy", rule)
+		}
+		switch strategy {
+		case "mask":
+			rules[key] = maskValue
+		case "hash":
+			rules[key] = hashValue(hashKey)
+		case "ip":
+			rules[key] = truncateIP
+		case "digits":
+			rules[key] = maskDigitRuns
+		case "drop":
+			rules[key] = dropValue
+		case "dsn":
+			rules[key] = redactDSN
+		case "query":
+			queryKeys = append(queryKeys, key)
+		default:
+			return nil, fmt.Errorf("invalid redaction rule %q: unknown strategy %q", rule, strategy)
+		}
+	}
+	for _, key := range queryKeys {
+		rules[key] = rules.redactQuery
+	}
+	return rules, nil
+}
+
+func (rr redactRules) redactQuery(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return redactedValue
+	}
+	for name, vs := range values {
+		fn, ok := rr[name]
+		if !ok {
+			continue
+		}
+		for i := range vs {
+			vs[i] = fn(vs[i])
+		}
+	}
+	enc := values.Encode()
+	if unescaped, err := url.QueryUnescape(enc); err == nil {
+		return unescaped
+	}
+	return enc
+}
+
+func maskValue(s string) string {
+	r := []rune(s)
+	if len(r) <= visibleDigits {
+		return strings.Repeat("*", len(r))
+	}
+	return strings.Repeat("*", len(r)-visibleDigits) + string(r[len(r)-visibleDigits:])
+}
+
+func hashValue(key []byte) redactor {
+	return func(s string) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(s))
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil))[:hashLen]
+	}
+}
+
+func redactDSN(s string) string {
+	if !strings.Contains(s, "://") {
+		return dsnPassword.ReplaceAllString(s, "password="+redactedValue)
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return redactedValue
+	}
+	if q := u.Query(); q.Has("password") {
+		q.Set("password", redactedValue)
+		u.RawQuery = q.Encode()
+	}
+	return u.Redacted()
+}
+
+func truncateIP(s string) string {
+	host := s
+	if h, _, err := net.SplitHostPort(s); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return redactedValue
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+func maskDigitRuns(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		if s[i] < '0' || s[i] > '9' {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			j++
+		}
+		if j-i >= minDigitRun {
+			b.WriteString(maskValue(s[i:j]))
+		} else {
+			b.WriteString(s[i:j])
+		}
+		i = j
+	}
+	return b.String()
+}
+
+func dropValue(string) string {
+	return redactedValue
+}
+
+type redactCore struct {
+	zapcore.Core
+	rules redactRules
+}
+
+func newRedactCore(core zapcore.Core, rules redactRules) zapcore.Core {
+	if len(rules) == 0 {
+		return core
+	}
+	return &redactCore{Core: core, rules: rules}
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.redact(fields)), rules: c.rules}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if fn, ok := c.rules[messageKey]; ok {
+		ent.Message = fn(ent.Message)
+	}
+	return c.Core.Write(ent, c.redact(fields))
+}
+
+func (c *redactCore) redact(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		fn, ok := c.rules[f.Key]
+		if !ok {
+			continue
+		}
+		s, ok := fieldString(f)
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = make([]zapcore.Field, len(fields))
+			copy(out, fields)
+		}
+		out[i] = zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: fn(s)}
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+func fieldString(f zapcore.Field) (string, bool) {
+	switch f.Type {
+	case zapcore.StringType:
+		return f.String, true
+	case zapcore.ByteStringType:
+		b, ok := f.Interface.([]byte)
+		return string(b), ok
+	case zapcore.StringerType:
+		if s, ok := f.Interface.(fmt.Stringer); ok {
+			return s.String(), true
+		}
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok {
+			return err.Error(), true
+		}
+	case zapcore.Int64Type, zapcore.Int32Type:
+		return fmt.Sprint(f.Integer), true
+	}
+	return "", false
+}
@@ -0,0 +1,222 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/configs"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	rawOrder = "12345678903"
+	rawLogin = "alice.secret"
+	rawIP    = "192.168.10.77"
+	rawPass  = "s3cr3t-pa55"
+)
+
+var rawSecrets = []string{rawOrder, rawLogin, rawIP, "51234", rawPass}
+
+func newTestLogger(t *testing.T, sampled bool) (*zap.Logger, *bytes.Buffer) {
+	t.Helper()
+
+	rules, err := parseRedactRules(configs.DefaultLogRedact, nil)
+	if err != nil {
+		t.Fatalf("parse default rules: %v", err)
+	}
+
+	var buf bytes.Buffer
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	core := newRedactCore(zapcore.NewCore(enc, zapcore.AddSync(&buf), zapcore.DebugLevel), rules)
+	if sampled {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, samplingFirst, samplingThereafter)
+	}
+	return zap.New(core), &buf
+}
+
+func assertNoSecrets(t *testing.T, out string) {
+	t.Helper()
+	if out == "" {
+		t.Fatal("nothing was written to the sink")
+	}
+	for _, s := range rawSecrets {
+		if strings.Contains(out, s) {
+			t.Errorf("raw value %q reached the sink:\n%s", s, out)
+		}
+	}
+}
+
+func TestRedactCoreLogPaths(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(l *zap.Logger)
+	}{
+		{
+			name: "direct fields",
+			log: func(l *zap.Logger) {
+				l.Info("http request",
+					zap.String("order", rawOrder),
+					zap.String("login", rawLogin),
+					zap.String("remote_addr", rawIP+":51234"),
+				)
+			},
+		},
+		{
+			name: "with fields",
+			log: func(l *zap.Logger) {
+				l.With(zap.String("login", rawLogin)).
+					With(zap.String("remote_addr", rawIP+":51234")).
+					Warn("rejected", zap.String("order", rawOrder))
+			},
+		},
+		{
+			name: "context fields",
+			log: func(l *zap.Logger) {
+				ctx := logctx.WithFields(context.Background(), zap.String("login", rawLogin), zap.String("remote_addr", rawIP))
+				ctx = logctx.WithFields(ctx, zap.String("order", rawOrder))
+				logctx.FromContext(ctx, l).Error("failed")
+			},
+		},
+		{
+			name: "query and path",
+			log: func(l *zap.Logger) {
+				l.Info("http request",
+					zap.String("path", "/api/admin/orders/"+rawOrder+"/repoll"),
+					zap.String("query", "login="+rawLogin+"&number="+rawOrder+"&status=NEW"),
+				)
+			},
+		},
+		{
+			name: "message and error",
+			log: func(l *zap.Logger) {
+				l.Error("failed to update order "+rawOrder, zap.Error(errors.New("order "+rawOrder+" is locked")))
+			},
+		},
+		{
+			name: "stringer and bytes",
+			log: func(l *zap.Logger) {
+				l.Info("values", zap.ByteString("login", []byte(rawLogin)), zap.Stringer("order", stringer(rawOrder)))
+			},
+		},
+		{
+			name: "dsn",
+			log: func(l *zap.Logger) {
+				l.Info("connecting", zap.String("dsn", "postgres://gophermart:"+rawPass+"@db:5432/gophermart?sslmode=disable"))
+				l.Info("connecting", zap.String("dsn", "postgres://db/gophermart?user=gophermart&password="+rawPass))
+				l.Info("connecting", zap.String("dsn", "host=db user=gophermart password='"+rawPass+" x' dbname=gophermart"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, sampled := range []bool{false, true} {
+			t.Run(tt.name, func(t *testing.T) {
+				l, buf := newTestLogger(t, sampled)
+				tt.log(l)
+				assertNoSecrets(t, buf.String())
+			})
+		}
+	}
+}
+
+func TestRedactCoreKeepsOtherFields(t *testing.T) {
+	l, buf := newTestLogger(t, false)
+	l.Info("http request", zap.String("method", "GET"), zap.Int("status", 200), zap.String("order", rawOrder))
+
+	out := buf.String()
+	for _, want := range []string{`"method":"GET"`, `"status":200`, `"order":"*******8903"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %s does not contain %s", out, want)
+		}
+	}
+}
+
+func TestRedactors(t *testing.T) {
+	key := []byte("log-hash-key")
+	tests := []struct {
+		name string
+		fn   redactor
+		in   string
+		want string
+	}{
+		{"mask", maskValue, rawOrder, "*******8903"},
+		{"mask short", maskValue, "123", "***"},
+		{"hash stable", hashValue(key), rawLogin, hashValue(key)(rawLogin)},
+		{"ipv4 with port", truncateIP, rawIP + ":51234", "192.168.10.0/24"},
+		{"ipv6 with port", truncateIP, "[2001:db8:abcd:12::1]:443", "2001:db8:abcd::/48"},
+		{"not an ip", truncateIP, "localhost", redactedValue},
+		{"digit runs", maskDigitRuns, "/orders/" + rawOrder + "/users/42", "/orders/*******8903/users/42"},
+		{"drop", dropValue, rawLogin, redactedValue},
+		{"dsn url", redactDSN, "postgres://app:" + rawPass + "@db:5432/app", "postgres://app:xxxxx@db:5432/app"},
+		{"dsn url without password", redactDSN, "postgres://app@db/app", "postgres://app@db/app"},
+		{"dsn keywords", redactDSN, "host=db password=" + rawPass + " dbname=app", "host=db password=" + redactedValue + " dbname=app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fn(tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	h := hashValue(key)(rawLogin)
+	if strings.Contains(h, rawLogin) || !strings.HasPrefix(h, "hmac:") {
+		t.Errorf("unexpected hash %q", h)
+	}
+	plain := sha256.Sum256([]byte(rawLogin))
+	if strings.Contains(h, hex.EncodeToString(plain[:])[:hashLen]) {
+		t.Errorf("hash %q is not keyed", h)
+	}
+	if other := hashValue([]byte("other-key"))(rawLogin); other == h {
+		t.Errorf("different keys produced the same hash %q", h)
+	}
+}
+
+func TestParseRedactRules(t *testing.T) {
+	if rules, err := parseRedactRules(RedactNone, nil); err != nil || rules != nil {
+		t.Errorf("none: got %v, %v", rules, err)
+	}
+	for _, spec := range []string{"order", "=mask", "order=bogus"} {
+		if _, err := parseRedactRules(spec, nil); err == nil {
+			t.Errorf("spec %q: expected error", spec)
+		}
+	}
+}
+
+func TestNewLoggerRedactsFileOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	l, _, err := NewLogger(&configs.ServerConfig{
+		LogLevel:  "info",
+		LogMode:   ModeProd,
+		LogOutput: path,
+		LogRedact: configs.DefaultLogRedact,
+	})
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+
+	l.With(zap.String("login", rawLogin)).Info("order "+rawOrder, zap.String("remote_addr", rawIP+":51234"))
+	if err = l.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	assertNoSecrets(t, string(out))
+}
+
+type stringer string
+
+func (s stringer) String() string { return string(s) }
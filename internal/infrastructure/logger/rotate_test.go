@@ -0,0 +1,101 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	rf, err := newRotatingFile(path, 10, 0, 5)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { rf.file.Close() })
+
+	for _, line := range []string{"first\n", "second\n"} {
+		if _, err = rf.Write([]byte(line)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 1 {
+		t.Fatalf("got %d backups, want 1", len(backups))
+	}
+	if data, _ := os.ReadFile(path); string(data) != "second\n" {
+		t.Fatalf("active file contains %q", data)
+	}
+}
+
+func TestRotatingFilePruneKeepsForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var backups []string
+	for i := range 4 {
+		b := path + "." + base.Add(time.Duration(i)*time.Minute).Format(backupTimeFormat)
+		backups = append(backups, b)
+	}
+	foreign := []string{path + ".lock", path + ".old", path + ".gz", path + "." + base.Format("2006-01-02")}
+	for _, f := range append(append([]string{}, backups...), foreign...) {
+		if err := os.WriteFile(f, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rf := &rotatingFile{path: path, maxBackups: 2}
+	rf.prune()
+
+	for i, b := range backups {
+		_, err := os.Stat(b)
+		if kept := err == nil; kept != (i >= 2) {
+			t.Errorf("backup %s kept=%v", filepath.Base(b), kept)
+		}
+	}
+	for _, f := range foreign {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("foreign file %s was removed", filepath.Base(f))
+		}
+	}
+}
+
+func TestRotatingFileKeepsWritingWhenRenameFails(t *testing.T) {
+	calls := 0
+	renameFile = func(string, string) error {
+		calls++
+		return errors.New("rename denied")
+	}
+	t.Cleanup(func() { renameFile = os.Rename })
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	rf, err := newRotatingFile(path, 10, 0, 5)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { rf.file.Close() })
+
+	if _, err = rf.Write([]byte("0123456789")); err != nil {
+		t.Fatalf("first write: %v", err)
+	}
+	n, err := rf.Write([]byte("next\n"))
+	if err == nil || n != 5 {
+		t.Fatalf("write during failed rotation: n=%d err=%v, want the line written and the error reported", n, err)
+	}
+	if _, err = rf.Write([]byte("more\n")); err != nil {
+		t.Fatalf("write after failed rotation: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("rotation retried %d times, want once until the file fills up again", calls)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.HasSuffix(string(data), "next\nmore\n") {
+		t.Fatalf("active file contains %q", data)
+	}
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/worker"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const collectTimeout = 2 * time.Second
+
+type OrderCounter interface {
+	CountOrdersByStatus(ctx context.Context, statuses []string) (map[string]int64, error)
+}
+
+type ordersCollector struct {
+	src    OrderCounter
+	logger *zap.Logger
+	depth  *prometheus.Desc
+}
+
+func NewOrdersCollector(src OrderCounter, logger *zap.Logger) prometheus.Collector {
+	return &ordersCollector{
+		src:    src,
+		logger: logger,
+		depth: prometheus.NewDesc(namespace+"_orders_pending",
+			"Orders awaiting accrual by status.", []string{"status"}, nil),
+	}
+}
+
+func (c *ordersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.depth
+}
+
+func (c *ordersCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	statuses := []string{models.StatusNew, models.StatusProcessing}
+	counts, err := c.src.CountOrdersByStatus(ctx, statuses)
+	if err != nil {
+		c.logger.Warn("failed to count orders for metrics", zap.Error(err))
+		return
+	}
+	for _, s := range statuses {
+		ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(counts[s]), s)
+	}
+}
+
+type poolCollector struct {
+	stat     func() *pgxpool.Stat
+	acquired *prometheus.Desc
+	idle     *prometheus.Desc
+	total    *prometheus.Desc
+	max      *prometheus.Desc
+	acquires *prometheus.Desc
+	empty    *prometheus.Desc
+	canceled *prometheus.Desc
+	waited   *prometheus.Desc
+}
+
+func NewPoolCollector(stat func() *pgxpool.Stat) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(namespace+"_db_pool_"+name, help, nil, nil)
+	}
+	return &poolCollector{
+		stat:     stat,
+		acquired: desc("acquired_conns", "Connections currently acquired from the pool."),
+		idle:     desc("idle_conns", "Idle connections in the pool."),
+		total:    desc("total_conns", "Total connections in the pool."),
+		max:      desc("max_conns", "Maximum size of the pool."),
+		acquires: desc("acquires_total", "Successful connection acquires."),
+		empty:    desc("empty_acquires_total", "Acquires that had to wait for a connection."),
+		canceled: desc("canceled_acquires_total", "Acquires canceled by context."),
+		waited:   desc("acquire_duration_seconds_total", "Total time spent acquiring connections."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stat()
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.empty, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceled, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.waited, prometheus.CounterValue, s.AcquireDuration().Seconds())
+}
+
+type workerCollector struct {
+	stats      func() worker.Stats
+	workers    *prometheus.Desc
+	busy       *prometheus.Desc
+	queued     *prometheus.Desc
+	tasks      *prometheus.Desc
+	panics     *prometheus.Desc
+	avgLatency *prometheus.Desc
+	maxLatency *prometheus.Desc
+}
+
+func NewWorkerCollector(name string, stats func() worker.Stats) prometheus.Collector {
+	labels := prometheus.Labels{"pool": name}
+	desc := func(metric, help string, variable ...string) *prometheus.Desc {
+		return prometheus.NewDesc(namespace+"_worker_"+metric, help, variable, labels)
+	}
+	return &workerCollector{
+		stats:      stats,
+		workers:    desc("workers", "Configured workers in the pool."),
+		busy:       desc("busy", "Workers currently running a task."),
+		queued:     desc("queued", "Tasks waiting for a free worker."),
+		tasks:      desc("tasks_total", "Finished tasks by result.", "result"),
+		panics:     desc("panics_total", "Tasks that panicked."),
+		avgLatency: desc("task_duration_seconds_avg", "Average task run time."),
+		maxLatency: desc("task_duration_seconds_max", "Longest task run time."),
+	}
+}
+
+func (c *workerCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *workerCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats()
+	ch <- prometheus.MustNewConstMetric(c.workers, prometheus.GaugeValue, float64(s.Workers))
+	ch <- prometheus.MustNewConstMetric(c.busy, prometheus.GaugeValue, float64(s.Busy))
+	ch <- prometheus.MustNewConstMetric(c.queued, prometheus.GaugeValue, float64(s.Queued))
+	ch <- prometheus.MustNewConstMetric(c.tasks, prometheus.CounterValue, float64(s.Completed), "completed")
+	ch <- prometheus.MustNewConstMetric(c.tasks, prometheus.CounterValue, float64(s.Failed), "failed")
+	ch <- prometheus.MustNewConstMetric(c.panics, prometheus.CounterValue, float64(s.Panicked))
+	ch <- prometheus.MustNewConstMetric(c.avgLatency, prometheus.GaugeValue, s.AvgLatency.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.maxLatency, prometheus.GaugeValue, s.MaxLatency.Seconds())
+}
+
+type rateCollector struct {
+	rates func() map[string]float64
+	rps   *prometheus.Desc
+}
+
+func NewRateCollector(rates func() map[string]float64) prometheus.Collector {
+	return &rateCollector{
+		rates: rates,
+		rps: prometheus.NewDesc(namespace+"_accrual_rate_limit_rps",
+			"Current adaptive request rate to accrual by provider.", []string{"provider"}, nil),
+	}
+}
+
+func (c *rateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.rps
+}
+
+func (c *rateCollector) Collect(ch chan<- prometheus.Metric) {
+	for provider, rps := range c.rates() {
+		ch <- prometheus.MustNewConstMetric(c.rps, prometheus.GaugeValue, rps, provider)
+	}
+}
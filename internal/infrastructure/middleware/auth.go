@@ -2,23 +2,44 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"slices"
+	"strconv"
 
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
 	"go.uber.org/zap"
 )
 
 type TokenValidator interface {
-	Validate(token string) (int64, error)
+	Validate(token string) (*models.Principal, error)
+}
+
+type KeyAuthenticator interface {
+	AuthenticateKey(ctx context.Context, key string) (*models.APIKey, error)
 }
 
 type contextKey string
 
-const userIDKey contextKey = "user_id"
+const principalKey contextKey = "principal"
 
-func Auth(logger *zap.Logger, tv TokenValidator) func(http.Handler) http.Handler {
+func Auth(logger *zap.Logger, tv TokenValidator, ka KeyAuthenticator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			mLog := logger.With(zap.String("middleware", "auth"))
+			mLog := logctx.FromContext(r.Context(), logger).With(zap.String("middleware", "auth"))
+
+			if rawKey := r.Header.Get("X-API-Key"); rawKey != "" {
+				principal, status := authenticateKey(r, ka, rawKey, mLog)
+				if principal == nil {
+					http.Error(w, http.StatusText(status), status)
+					return
+				}
+				ctx := context.WithValue(r.Context(), principalKey, principal)
+				ctx = logctx.WithFields(ctx, zap.Int64("user_id", principal.UserID), zap.Int64("api_key_id", principal.APIKeyID))
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
 
 			c, err := r.Cookie("access_token")
 			if err != nil {
@@ -26,24 +47,126 @@ func Auth(logger *zap.Logger, tv TokenValidator) func(http.Handler) http.Handler
 				return
 			}
 
-			userID, err := tv.Validate(c.Value)
-			if err != nil || userID <= 0 {
+			principal, err := tv.Validate(c.Value)
+			if err != nil || principal.UserID <= 0 {
 				mLog.Error("invalid jwt token", zap.Error(err))
 				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), userIDKey, userID)
+			ctx := context.WithValue(r.Context(), principalKey, principal)
+			ctx = logctx.WithFields(ctx, zap.Int64("user_id", principal.UserID))
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+func authenticateKey(r *http.Request, ka KeyAuthenticator, rawKey string, mLog *zap.Logger) (*models.Principal, int) {
+	key, err := ka.AuthenticateKey(r.Context(), rawKey)
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyRateLimited) {
+			return nil, http.StatusTooManyRequests
+		}
+		if errors.Is(err, models.ErrAPIKeyInvalid) {
+			mLog.Warn("invalid api key")
+			return nil, http.StatusUnauthorized
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, http.StatusRequestTimeout
+		}
+		mLog.Error("failed to authenticate api key", zap.Error(err))
+		return nil, http.StatusInternalServerError
+	}
+
+	var userID int64
+	if v := r.Header.Get("X-User-ID"); v != "" {
+		userID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || userID <= 0 {
+			return nil, http.StatusBadRequest
+		}
+	} else if len(key.UserIDs) == 1 {
+		userID = key.UserIDs[0]
+	} else {
+		return nil, http.StatusBadRequest
+	}
+
+	if !slices.Contains(key.UserIDs, userID) {
+		mLog.Warn("api key is not allowed to act on behalf of user", zap.Int64("api_key_id", key.ID), zap.Int64("user_id", userID))
+		return nil, http.StatusForbidden
+	}
+
+	return &models.Principal{
+		UserID:   userID,
+		Role:     models.RoleService,
+		APIKeyID: key.ID,
+		Scopes:   key.Scopes,
+	}, 0
+}
+
+func RequireScope(logger *zap.Logger, scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mLog := logctx.FromContext(r.Context(), logger).With(zap.String("middleware", "require_scope"))
+
+			principal, ok := GetPrincipalFromContext(r.Context())
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			if !principal.HasScope(scope) {
+				mLog.Warn("scope denied",
+					zap.Int64("api_key_id", principal.APIKeyID),
+					zap.String("scope", scope),
+					zap.String("path", r.URL.Path),
+				)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func RequireRole(logger *zap.Logger, roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mLog := logctx.FromContext(r.Context(), logger).With(zap.String("middleware", "require_role"))
+
+			principal, ok := GetPrincipalFromContext(r.Context())
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			if !principal.HasRole(roles...) {
+				mLog.Warn("access denied",
+					zap.Int64("user_id", principal.UserID),
+					zap.String("role", principal.Role),
+					zap.String("path", r.URL.Path),
+				)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func GetPrincipalFromContext(ctx context.Context) (*models.Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(*models.Principal)
+	if !ok || principal == nil {
+		return nil, false
+	}
+	return principal, true
+}
+
 func GetUserIDFromContext(ctx context.Context) (int64, bool) {
-	val := ctx.Value(userIDKey)
-	if val == nil {
+	principal, ok := GetPrincipalFromContext(ctx)
+	if !ok {
 		return 0, false
 	}
-	id, ok := val.(int64)
-	return id, ok
+	return principal.UserID, true
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
 	"go.uber.org/zap"
 )
 
@@ -98,7 +99,7 @@ func (cw *compressWriter) Close() error {
 func Compress(logger *zap.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			mLog := logger.With(zap.String("middleware", "compress"))
+			mLog := logctx.FromContext(r.Context(), logger).With(zap.String("middleware", "compress"))
 
 			if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") && r.Body != nil {
 				cr, err := newCompressReader(r.Body)
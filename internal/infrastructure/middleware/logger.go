@@ -2,8 +2,12 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/metrics"
+	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
@@ -38,15 +42,27 @@ func Logger(logger *zap.Logger) func(http.Handler) http.Handler {
 			}
 
 			next.ServeHTTP(lrw, r)
+			duration := time.Since(start)
 
-			mLog.Info("http request",
+			route := "unmatched"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			status := strconv.Itoa(http.StatusOK)
+			if lrw.status != 0 {
+				status = strconv.Itoa(lrw.status)
+			}
+			metrics.HTTPRequests.WithLabelValues(r.Method, route, status).Inc()
+			metrics.HTTPDuration.WithLabelValues(r.Method, route, status).Observe(duration.Seconds())
+
+			logctx.FromContext(r.Context(), mLog).Info("http request",
 				zap.String("method", r.Method),
 				zap.String("path", r.URL.Path),
 				zap.String("query", r.URL.RawQuery),
 				zap.String("remote_addr", r.RemoteAddr),
 				zap.Int("status", lrw.status),
 				zap.Int("size", lrw.size),
-				zap.Duration("duration", time.Since(start)),
+				zap.Duration("duration", duration),
 			)
 		})
 	}
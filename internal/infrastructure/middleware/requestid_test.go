@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const testRequestID = "req-42"
+
+type rejectingValidator struct{}
+
+func (rejectingValidator) Validate(string) (*models.Principal, error) {
+	return nil, errors.New("token expired")
+}
+
+type failingKeys struct{ err error }
+
+func (k failingKeys) AuthenticateKey(context.Context, string) (*models.APIKey, error) {
+	return nil, k.err
+}
+
+type staticKeys struct{ key *models.APIKey }
+
+func (k staticKeys) AuthenticateKey(context.Context, string) (*models.APIKey, error) {
+	return k.key, nil
+}
+
+func withPrincipal(p *models.Principal) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey, p)))
+		})
+	}
+}
+
+func TestMiddlewareLogsCarryRequestID(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {})
+
+	tests := []struct {
+		name    string
+		handler func(l *zap.Logger) http.Handler
+		request func() *http.Request
+	}{
+		{
+			name: "invalid jwt",
+			handler: func(l *zap.Logger) http.Handler {
+				return Auth(l, rejectingValidator{}, failingKeys{})(ok)
+			},
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+				r.AddCookie(&http.Cookie{Name: "access_token", Value: "bad"})
+				return r
+			},
+		},
+		{
+			name: "invalid api key",
+			handler: func(l *zap.Logger) http.Handler {
+				return Auth(l, rejectingValidator{}, failingKeys{err: models.ErrAPIKeyInvalid})(ok)
+			},
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+				r.Header.Set("X-API-Key", "bad")
+				return r
+			},
+		},
+		{
+			name: "api key lookup failure",
+			handler: func(l *zap.Logger) http.Handler {
+				return Auth(l, rejectingValidator{}, failingKeys{err: errors.New("db down")})(ok)
+			},
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+				r.Header.Set("X-API-Key", "key")
+				return r
+			},
+		},
+		{
+			name: "api key acting for foreign user",
+			handler: func(l *zap.Logger) http.Handler {
+				return Auth(l, rejectingValidator{}, staticKeys{key: &models.APIKey{ID: 3, UserIDs: []int64{1}}})(ok)
+			},
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+				r.Header.Set("X-API-Key", "key")
+				r.Header.Set("X-User-ID", "2")
+				return r
+			},
+		},
+		{
+			name: "scope denied",
+			handler: func(l *zap.Logger) http.Handler {
+				return withPrincipal(&models.Principal{UserID: 1, Role: models.RoleService, APIKeyID: 3})(RequireScope(l, models.ScopeOrdersWrite)(ok))
+			},
+			request: func() *http.Request { return httptest.NewRequest(http.MethodPost, "/api/user/orders", nil) },
+		},
+		{
+			name: "role denied",
+			handler: func(l *zap.Logger) http.Handler {
+				return withPrincipal(&models.Principal{UserID: 1, Role: models.RoleUser})(RequireRole(l, models.RoleAdmin)(ok))
+			},
+			request: func() *http.Request { return httptest.NewRequest(http.MethodGet, "/api/admin/users", nil) },
+		},
+		{
+			name: "missing signature",
+			handler: func(l *zap.Logger) http.Handler {
+				return VerifySignature(l, []byte("secret"))(ok)
+			},
+			request: func() *http.Request { return httptest.NewRequest(http.MethodPost, "/api/accrual/callback", nil) },
+		},
+		{
+			name: "invalid signature",
+			handler: func(l *zap.Logger) http.Handler {
+				return VerifySignature(l, []byte("secret"))(ok)
+			},
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/api/accrual/callback", strings.NewReader("{}"))
+				r.Header.Set(SignatureHeader, signaturePrefix+"00ff")
+				r.Header.Set(TimestampHeader, strconv.FormatInt(time.Now().Unix(), 10))
+				return r
+			},
+		},
+		{
+			name: "malformed gzip body",
+			handler: func(l *zap.Logger) http.Handler {
+				return Compress(l)(ok)
+			},
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/api/user/orders", strings.NewReader("not gzip"))
+				r.Header.Set("Content-Encoding", "gzip")
+				return r
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			req := tt.request()
+			req.Header.Set(RequestIDHeader, testRequestID)
+
+			RequestID(tt.handler(zap.New(core))).ServeHTTP(httptest.NewRecorder(), req)
+
+			entries := logs.All()
+			if len(entries) == 0 {
+				t.Fatal("nothing was logged")
+			}
+			for _, e := range entries {
+				if got := e.ContextMap()["request_id"]; got != testRequestID {
+					t.Errorf("entry %q has request_id %v, want %s", e.Message, got, testRequestID)
+				}
+			}
+		})
+	}
+}
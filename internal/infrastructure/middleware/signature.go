@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
+	"go.uber.org/zap"
+)
+
+const (
+	SignatureHeader  = "X-Accrual-Signature"
+	TimestampHeader  = "X-Accrual-Timestamp"
+	signaturePrefix  = "sha256="
+	maxSignedBody    = 1 << 20
+	maxSignatureSkew = 5 * time.Minute
+)
+
+func Sign(secret []byte, ts time.Time, body []byte) string {
+	return signaturePrefix + hex.EncodeToString(signature(secret, strconv.FormatInt(ts.Unix(), 10), body))
+}
+
+func signature(secret []byte, ts string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func VerifySignature(logger *zap.Logger, secret []byte) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mLog := logctx.FromContext(r.Context(), logger)
+
+			sig, err := hex.DecodeString(strings.TrimPrefix(r.Header.Get(SignatureHeader), signaturePrefix))
+			if err != nil || len(sig) == 0 {
+				mLog.Debug("missing or malformed request signature")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			ts := r.Header.Get(TimestampHeader)
+			sec, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				mLog.Debug("missing or malformed request timestamp")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if skew := time.Since(time.Unix(sec, 0)); skew > maxSignatureSkew || skew < -maxSignatureSkew {
+				mLog.Warn("request timestamp outside of allowed skew", zap.String("remote_addr", r.RemoteAddr), zap.Duration("skew", skew.Round(time.Second)))
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBody))
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			r.Body.Close()
+
+			if !hmac.Equal(sig, signature(secret, ts, body)) {
+				mLog.Warn("invalid request signature", zap.String("remote_addr", r.RemoteAddr))
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Tracing(skip ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		routed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+
+			if route := routePattern(r); route != "" {
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + route)
+				span.SetAttributes(semconv.HTTPRoute(route))
+			}
+		})
+
+		return otelhttp.NewHandler(routed, "http",
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				if route := routePattern(r); route != "" {
+					return r.Method + " " + route
+				}
+				return r.Method
+			}),
+			otelhttp.WithFilter(func(r *http.Request) bool {
+				for _, p := range skip {
+					if r.URL.Path == p {
+						return false
+					}
+				}
+				return true
+			}),
+		)
+	}
+}
+
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
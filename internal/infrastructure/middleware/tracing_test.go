@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/tracing"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestTracingNamesSpanByRoute(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := tracing.Install(exp, "test")
+	defer tp.Shutdown(context.Background())
+
+	r := chi.NewRouter()
+	r.Use(Tracing("/healthz"))
+	r.Get("/healthz", func(w http.ResponseWriter, _ *http.Request) {})
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Post("/orders/{number}/repoll", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		})
+	})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/api/admin/orders/12345678903/repoll", nil),
+		httptest.NewRequest(http.MethodGet, "/healthz", nil),
+		httptest.NewRequest(http.MethodGet, "/missing", nil),
+	} {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+
+	if got, want := spans[0].Name, "POST /api/admin/orders/{number}/repoll"; got != want {
+		t.Errorf("span name %q, want %q", got, want)
+	}
+	var route string
+	for _, a := range spans[0].Attributes {
+		if a.Key == semconv.HTTPRouteKey {
+			route = a.Value.AsString()
+		}
+	}
+	if route != "/api/admin/orders/{number}/repoll" {
+		t.Errorf("http.route %q", route)
+	}
+
+	if got, want := spans[1].Name, "GET"; got != want {
+		t.Errorf("unmatched span name %q, want %q", got, want)
+	}
+}
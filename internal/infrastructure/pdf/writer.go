@@ -0,0 +1,150 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	margin       = 50
+	fontSize     = 10
+	leading      = 14
+	linesPerPage = (pageHeight - 2*margin) / leading
+)
+
+const (
+	catalogID = iota + 1
+	pagesID
+	regularFontID
+	boldFontID
+	firstFreeID
+)
+
+type Writer struct {
+	w       io.Writer
+	written int64
+	offsets map[int]int64
+	nextID  int
+	pageIDs []int
+	content bytes.Buffer
+	lines   int
+	err     error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	pw := &Writer{
+		w:       w,
+		offsets: make(map[int]int64),
+		nextID:  firstFreeID,
+	}
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	pw.writeObject(regularFontID, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	pw.writeObject(boldFontID, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	return pw
+}
+
+func (pw *Writer) Heading(text string) error {
+	return pw.line("F2", text)
+}
+
+func (pw *Writer) Text(text string) error {
+	return pw.line("F1", text)
+}
+
+func (pw *Writer) Close() error {
+	if pw.lines > 0 || len(pw.pageIDs) == 0 {
+		pw.flushPage()
+	}
+
+	kids := make([]string, 0, len(pw.pageIDs))
+	for _, id := range pw.pageIDs {
+		kids = append(kids, fmt.Sprintf("%d 0 R", id))
+	}
+	pw.writeObject(pagesID, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pw.pageIDs)))
+	pw.writeObject(catalogID, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+
+	xrefOffset := pw.written
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", pw.nextID)
+	for id := 1; id < pw.nextID; id++ {
+		pw.printf("%010d 00000 n \n", pw.offsets[id])
+	}
+	pw.printf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", pw.nextID, catalogID, xrefOffset)
+
+	return pw.err
+}
+
+func (pw *Writer) line(font, text string) error {
+	if pw.err != nil {
+		return pw.err
+	}
+	if pw.lines == linesPerPage {
+		pw.flushPage()
+	}
+	if pw.lines == 0 {
+		fmt.Fprintf(&pw.content, "BT\n%d TL\n%d %d Td\n", leading, margin, pageHeight-margin-fontSize)
+	}
+	fmt.Fprintf(&pw.content, "/%s %d Tf\n(%s) Tj T*\n", font, fontSize, escape(text))
+	pw.lines++
+	return pw.err
+}
+
+func (pw *Writer) flushPage() {
+	if pw.lines > 0 {
+		pw.content.WriteString("ET\n")
+	}
+
+	contentID := pw.allocID()
+	pw.writeObject(contentID, fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", pw.content.Len(), pw.content.String()))
+
+	pageID := pw.allocID()
+	pw.writeObject(pageID, fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Contents %d 0 R /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> >>",
+		pagesID, pageWidth, pageHeight, contentID, regularFontID, boldFontID,
+	))
+	pw.pageIDs = append(pw.pageIDs, pageID)
+
+	pw.content.Reset()
+	pw.lines = 0
+}
+
+func (pw *Writer) allocID() int {
+	id := pw.nextID
+	pw.nextID++
+	return id
+}
+
+func (pw *Writer) writeObject(id int, body string) {
+	pw.offsets[id] = pw.written
+	pw.printf("%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+func (pw *Writer) printf(format string, args ...any) {
+	if pw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(pw.w, format, args...)
+	pw.written += int64(n)
+	pw.err = err
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
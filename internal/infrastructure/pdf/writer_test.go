@@ -0,0 +1,133 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var (
+	startxrefRe = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`)
+	countRe     = regexp.MustCompile(`/Type /Pages /Kids \[[^\]]*\] /Count (\d+)`)
+	streamRe    = regexp.MustCompile(`(?s)<< /Length (\d+) >>\nstream\n(.*?)endstream`)
+)
+
+func render(t *testing.T, lines int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.Heading("Statement (test)"); err != nil {
+		t.Fatalf("heading: %v", err)
+	}
+	for i := 1; i < lines; i++ {
+		if err := w.Text(fmt.Sprintf("line %d", i)); err != nil {
+			t.Fatalf("text: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriterXrefOffsets(t *testing.T) {
+	doc := render(t, 2*linesPerPage+3)
+
+	m := startxrefRe.FindSubmatch(doc)
+	if m == nil {
+		t.Fatal("startxref trailer not found")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(doc[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+
+	lines := strings.Split(string(doc[xref:]), "\n")
+	var first, size int
+	if _, err := fmt.Sscanf(lines[1], "%d %d", &first, &size); err != nil || first != 0 {
+		t.Fatalf("xref subsection header %q", lines[1])
+	}
+	if lines[2] != "0000000000 65535 f " {
+		t.Fatalf("free entry %q", lines[2])
+	}
+	for id := 1; id < size; id++ {
+		entry := lines[2+id]
+		if len(entry) != 19 || !strings.HasSuffix(entry, " 00000 n ") {
+			t.Fatalf("xref entry %d %q is not 20 bytes", id, entry)
+		}
+		off, _ := strconv.Atoi(entry[:10])
+		if want := fmt.Sprintf("%d 0 obj\n", id); !bytes.HasPrefix(doc[off:], []byte(want)) {
+			t.Fatalf("object %d: offset %d points at %q", id, off, doc[off:min(off+20, len(doc))])
+		}
+	}
+	if want := fmt.Sprintf("/Size %d ", size); !bytes.Contains(doc, []byte(want)) {
+		t.Fatalf("trailer does not declare %s", want)
+	}
+
+	for _, sm := range streamRe.FindAllSubmatch(doc, -1) {
+		if n, _ := strconv.Atoi(string(sm[1])); n != len(sm[2]) {
+			t.Fatalf("stream /Length %d, actual %d", n, len(sm[2]))
+		}
+	}
+}
+
+func TestWriterPagination(t *testing.T) {
+	tests := []struct {
+		lines     int
+		wantPages int
+	}{
+		{1, 1},
+		{linesPerPage, 1},
+		{linesPerPage + 1, 2},
+		{3 * linesPerPage, 3},
+		{3*linesPerPage + 1, 4},
+	}
+	for _, tt := range tests {
+		doc := render(t, tt.lines)
+		m := countRe.FindSubmatch(doc)
+		if m == nil {
+			t.Fatalf("%d lines: pages object not found", tt.lines)
+		}
+		if got, _ := strconv.Atoi(string(m[1])); got != tt.wantPages {
+			t.Errorf("%d lines: got %d pages, want %d", tt.lines, got, tt.wantPages)
+		}
+		if got := bytes.Count(doc, []byte("/Type /Page /Parent")); got != tt.wantPages {
+			t.Errorf("%d lines: got %d page objects, want %d", tt.lines, got, tt.wantPages)
+		}
+		if got := bytes.Count(doc, []byte(") Tj T*")); got != tt.lines {
+			t.Errorf("%d lines: got %d text operators", tt.lines, got)
+		}
+	}
+}
+
+func TestWriterEmptyDocument(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if m := countRe.FindSubmatch(buf.Bytes()); m == nil || string(m[1]) != "1" {
+		t.Fatal("empty document must have a single blank page")
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain text 123", "plain text 123"},
+		{`(a) \ b`, `\(a\) \\ b`},
+		{"café", `caf\351`},
+		{"100 ₽", "100 ?"},
+		{"Баланс", "??????"},
+		{"tab\tnew\nline", "tab?new?line"},
+	}
+	for _, tt := range tests {
+		if got := escape(tt.in); got != tt.want {
+			t.Errorf("escape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
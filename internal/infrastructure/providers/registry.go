@@ -0,0 +1,118 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/breaker"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/httpclient"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/ratelimit"
+	"go.uber.org/zap"
+)
+
+type Endpoint struct {
+	Name             string
+	Address          string
+	RPS              float64
+	MaxInFlight      int
+	BreakerThreshold int
+	BreakerTimeout   time.Duration
+	BatchSize        int
+}
+
+type Route struct {
+	Prefix   string
+	APIKeyID int64
+	Provider string
+}
+
+type Options struct {
+	Default   string
+	Endpoints []Endpoint
+	Routes    []Route
+}
+
+type Provider struct {
+	Name    string
+	Client  *httpclient.AccrualClient
+	Limiter *ratelimit.Limiter
+}
+
+type Registry struct {
+	providers  []Provider
+	byPrefix   []Route
+	byAPIKey   map[int64]string
+	defaultKey string
+}
+
+func NewRegistry(opts Options, logger *zap.Logger) (*Registry, error) {
+	r := &Registry{
+		byAPIKey:   make(map[int64]string),
+		defaultKey: opts.Default,
+	}
+
+	names := make(map[string]struct{}, len(opts.Endpoints))
+	for _, ep := range opts.Endpoints {
+		if ep.Name == "" {
+			return nil, errors.New("accrual provider name is empty")
+		}
+		if _, ok := names[ep.Name]; ok {
+			return nil, fmt.Errorf("accrual provider %q is defined twice", ep.Name)
+		}
+		names[ep.Name] = struct{}{}
+	}
+	if _, ok := names[opts.Default]; !ok {
+		return nil, fmt.Errorf("default accrual provider %q is not configured", opts.Default)
+	}
+	for i, rt := range opts.Routes {
+		if (rt.Prefix == "") == (rt.APIKeyID == 0) {
+			return nil, fmt.Errorf("accrual route #%d: exactly one of prefix and api key is required", i+1)
+		}
+		if _, ok := names[rt.Provider]; !ok {
+			return nil, fmt.Errorf("accrual route #%d: provider %q is not configured", i+1, rt.Provider)
+		}
+	}
+
+	for _, ep := range opts.Endpoints {
+		cb := breaker.New("accrual:"+ep.Name, ep.BreakerThreshold, ep.BreakerTimeout, logger)
+		r.providers = append(r.providers, Provider{
+			Name:    ep.Name,
+			Client:  httpclient.NewAccrualClient(ep.Name, ep.Address, cb, ep.BatchSize),
+			Limiter: ratelimit.NewLimiter(ep.RPS, ep.MaxInFlight),
+		})
+	}
+
+	for _, rt := range opts.Routes {
+		if rt.APIKeyID != 0 {
+			r.byAPIKey[rt.APIKeyID] = rt.Provider
+			continue
+		}
+		r.byPrefix = append(r.byPrefix, rt)
+	}
+
+	return r, nil
+}
+
+func (r *Registry) Providers() []Provider {
+	return r.providers
+}
+
+func (r *Registry) Default() string {
+	return r.defaultKey
+}
+
+func (r *Registry) Route(number string, apiKeyID int64) string {
+	if name, ok := r.byAPIKey[apiKeyID]; ok && apiKeyID != 0 {
+		return name
+	}
+
+	name, best := r.defaultKey, 0
+	for _, rt := range r.byPrefix {
+		if len(rt.Prefix) > best && strings.HasPrefix(number, rt.Prefix) {
+			name, best = rt.Provider, len(rt.Prefix)
+		}
+	}
+	return name
+}
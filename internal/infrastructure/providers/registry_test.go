@@ -0,0 +1,86 @@
+package providers
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func testOptions() Options {
+	return Options{
+		Default: "main",
+		Endpoints: []Endpoint{
+			{Name: "main", Address: "http://main"},
+			{Name: "visa", Address: "http://visa"},
+			{Name: "visa-gold", Address: "http://visa-gold"},
+			{Name: "partner", Address: "http://partner"},
+		},
+		Routes: []Route{
+			{Prefix: "4", Provider: "visa"},
+			{Prefix: "4276", Provider: "visa-gold"},
+			{Prefix: "42", Provider: "visa"},
+			{APIKeyID: 7, Provider: "partner"},
+		},
+	}
+}
+
+func TestRegistryRoute(t *testing.T) {
+	r, err := NewRegistry(testOptions(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		number   string
+		apiKeyID int64
+		want     string
+	}{
+		{"no match uses default", "12345678903", 0, "main"},
+		{"short prefix", "4000000000000002", 0, "visa"},
+		{"longest prefix wins", "4276000000000006", 0, "visa-gold"},
+		{"longest prefix wins regardless of order", "4200000000000000", 0, "visa"},
+		{"api key overrides prefix", "4276000000000006", 7, "partner"},
+		{"api key overrides default", "12345678903", 7, "partner"},
+		{"unknown api key falls back to prefix", "4276000000000006", 8, "visa-gold"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Route(tt.number, tt.apiKeyID); got != tt.want {
+				t.Errorf("Route(%q, %d) = %q, want %q", tt.number, tt.apiKeyID, got, tt.want)
+			}
+		})
+	}
+
+	if got := r.Default(); got != "main" {
+		t.Errorf("Default() = %q, want main", got)
+	}
+	if got := len(r.Providers()); got != 4 {
+		t.Errorf("got %d providers, want 4", got)
+	}
+}
+
+func TestNewRegistryRejectsUnknownProviders(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *Options)
+	}{
+		{"unknown default", func(o *Options) { o.Default = "missing" }},
+		{"empty default", func(o *Options) { o.Default = "" }},
+		{"route to unknown provider", func(o *Options) { o.Routes[1].Provider = "missing" }},
+		{"api key route to unknown provider", func(o *Options) { o.Routes[3].Provider = "missing" }},
+		{"route without prefix and api key", func(o *Options) { o.Routes = append(o.Routes, Route{Provider: "main"}) }},
+		{"route with prefix and api key", func(o *Options) { o.Routes = append(o.Routes, Route{Prefix: "5", APIKeyID: 9, Provider: "main"}) }},
+		{"duplicate endpoint", func(o *Options) { o.Endpoints = append(o.Endpoints, Endpoint{Name: "visa"}) }},
+		{"unnamed endpoint", func(o *Options) { o.Endpoints = append(o.Endpoints, Endpoint{Address: "http://x"}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions()
+			tt.modify(&opts)
+			if _, err := NewRegistry(opts, zap.NewNop()); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	minRate        = 1.0 / 60
+	increaseFactor = 0.05
+	decreaseFactor = 0.5
+	throttleWindow = time.Second
+)
+
+type Limiter struct {
+	rl    *rate.Limiter
+	slots chan struct{}
+
+	mu          sync.Mutex
+	maxRate     float64
+	ceiling     float64
+	current     float64
+	pausedUntil time.Time
+	holdUntil   time.Time
+}
+
+func NewLimiter(rps float64, maxInFlight int) *Limiter {
+	if rps <= 0 {
+		rps = 1
+	}
+	if maxInFlight <= 0 {
+		maxInFlight = 1
+	}
+
+	return &Limiter{
+		rl:      rate.NewLimiter(rate.Limit(rps), burstFor(rps)),
+		slots:   make(chan struct{}, maxInFlight),
+		maxRate: rps,
+		ceiling: rps,
+		current: rps,
+	}
+}
+
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if err := l.Wait(ctx); err != nil {
+		<-l.slots
+		return nil, err
+	}
+
+	return func() { <-l.slots }, nil
+}
+
+func (l *Limiter) Wait(ctx context.Context) error {
+	if err := l.waitPause(ctx); err != nil {
+		return err
+	}
+	return l.rl.Wait(ctx)
+}
+
+func (l *Limiter) OnSuccess() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.current >= l.ceiling {
+		return
+	}
+	l.setRate(min(l.ceiling, l.current+l.ceiling*increaseFactor))
+}
+
+func (l *Limiter) OnThrottle(retryAfter time.Duration, limitPerMinute int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if limitPerMinute > 0 {
+		l.ceiling = min(l.maxRate, max(minRate, float64(limitPerMinute)/60))
+	}
+	if now.Before(l.holdUntil) {
+		l.setRate(min(l.ceiling, l.current))
+	} else {
+		l.setRate(min(l.ceiling, l.current*decreaseFactor))
+		l.holdUntil = now.Add(max(retryAfter, throttleWindow))
+	}
+
+	if until := now.Add(retryAfter); retryAfter > 0 && until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+func (l *Limiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.current
+}
+
+func (l *Limiter) setRate(r float64) {
+	l.current = max(minRate, r)
+	l.rl.SetLimit(rate.Limit(l.current))
+	l.rl.SetBurst(burstFor(l.current))
+}
+
+func (l *Limiter) waitPause(ctx context.Context) error {
+	l.mu.Lock()
+	d := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func burstFor(r float64) int {
+	return max(1, int(r))
+}
@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterThrottlesOncePerWindow(t *testing.T) {
+	l := NewLimiter(8, 4)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.OnThrottle(0, 0)
+		}()
+	}
+	wg.Wait()
+
+	if got := l.Rate(); got != 4 {
+		t.Fatalf("rate after concurrent 429s is %v, want 4", got)
+	}
+
+	time.Sleep(throttleWindow + 50*time.Millisecond)
+	l.OnThrottle(0, 0)
+	if got := l.Rate(); got != 2 {
+		t.Fatalf("rate after next window is %v, want 2", got)
+	}
+}
+
+func TestLimiterCeilingAppliesWithinWindow(t *testing.T) {
+	l := NewLimiter(8, 4)
+
+	l.OnThrottle(0, 0)
+	l.OnThrottle(0, 60)
+	if got := l.Rate(); got != 1 {
+		t.Fatalf("rate is %v, want the advertised limit of 1", got)
+	}
+}
+
+func TestLimiterRecoversOnSuccess(t *testing.T) {
+	l := NewLimiter(10, 4)
+
+	l.OnThrottle(0, 0)
+	for range 100 {
+		l.OnSuccess()
+	}
+	if got := l.Rate(); got != 10 {
+		t.Fatalf("rate is %v, want it restored to 10", got)
+	}
+}
@@ -0,0 +1,146 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestScheduleNext(t *testing.T) {
+	utc := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from string
+		want string
+	}{
+		{"every minute", "* * * * *", "2024-05-01 10:00", "2024-05-01 10:01"},
+		{"hour range", "0 9-17 * * *", "2024-05-01 17:30", "2024-05-02 09:00"},
+		{"hour range inside", "0 9-17 * * *", "2024-05-01 12:10", "2024-05-01 13:00"},
+		{"minute step", "*/15 * * * *", "2024-05-01 10:16", "2024-05-01 10:30"},
+		{"range with step", "10-40/10 * * * *", "2024-05-01 10:40", "2024-05-01 11:10"},
+		{"value with step", "5/20 * * * *", "2024-05-01 10:26", "2024-05-01 10:45"},
+		{"list", "0 6,18 * * *", "2024-05-01 07:00", "2024-05-01 18:00"},
+		{"day of week 7 is sunday", "0 0 * * 7", "2024-05-01 00:00", "2024-05-05 00:00"},
+		{"day of week 0 is sunday", "0 0 * * 0", "2024-05-01 00:00", "2024-05-05 00:00"},
+		{"weekday range", "0 9 * * 1-5", "2024-05-03 10:00", "2024-05-06 09:00"},
+		{"dom or dow: dom first", "0 0 13 * 5", "2024-09-10 00:00", "2024-09-13 00:00"},
+		{"dom or dow: dow first", "0 0 20 * 5", "2024-09-10 00:00", "2024-09-13 00:00"},
+		{"dom or dow: dom on other weekday", "0 0 15 * 5", "2024-09-13 00:00", "2024-09-15 00:00"},
+		{"dom with star dow", "0 0 31 * *", "2024-04-01 00:00", "2024-05-31 00:00"},
+		{"dow with star dom", "0 0 * * 1", "2024-04-30 00:00", "2024-05-06 00:00"},
+		{"dom step with star intersects with dow", "0 0 */10 * 1", "2024-05-02 00:00", "2024-07-01 00:00"},
+		{"month", "0 0 1 3 *", "2024-05-01 00:00", "2025-03-01 00:00"},
+		{"leap day", "0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		{"macro", "@monthly", "2024-05-15 00:00", "2024-06-01 00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("parse %q: %v", tt.spec, err)
+			}
+			from := utc(tt.from).Add(30 * time.Second)
+			if got, want := s.Next(from), utc(tt.want); !got.Equal(want) {
+				t.Errorf("Next(%s) = %s, want %s", from, got, want)
+			}
+		})
+	}
+}
+
+func TestScheduleNeverMatches(t *testing.T) {
+	for _, spec := range []string{"0 0 30 2 *", "0 0 31 4 *", "0 0 31 2,4,6,9,11 *"} {
+		s, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("parse %q: %v", spec, err)
+		}
+		if next := s.Next(time.Now()); !next.IsZero() {
+			t.Errorf("%q: expected no next run, got %s", spec, next)
+		}
+	}
+}
+
+func TestScheduleDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, loc)
+	}
+
+	t.Run("skipped hour", func(t *testing.T) {
+		s, _ := Parse("30 2 * * *")
+		if got, want := s.Next(at(2024, time.March, 10, 0, 0)), at(2024, time.March, 11, 2, 30); !got.Equal(want) {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("hourly across skipped hour", func(t *testing.T) {
+		s, _ := Parse("0 * * * *")
+		if got, want := s.Next(at(2024, time.March, 10, 1, 30)), at(2024, time.March, 10, 3, 0); !got.Equal(want) {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("repeated hour runs once", func(t *testing.T) {
+		s, _ := Parse("30 1 * * *")
+		first := s.Next(at(2024, time.November, 3, 0, 0))
+		if want := at(2024, time.November, 3, 1, 30); !first.Equal(want) {
+			t.Fatalf("got %s, want %s", first, want)
+		}
+		if got, want := s.Next(first), at(2024, time.November, 4, 1, 30); !got.Equal(want) {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("skipped midnight", func(t *testing.T) {
+		santiago, err := time.LoadLocation("America/Santiago")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, _ := Parse("30 1 * * *")
+		from := time.Date(2024, time.September, 7, 12, 0, 0, 0, santiago)
+		if got, want := s.Next(from), time.Date(2024, time.September, 8, 1, 30, 0, 0, santiago); !got.Equal(want) {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("daily keeps wall clock", func(t *testing.T) {
+		s, _ := Parse("0 12 * * *")
+		if got, want := s.Next(at(2024, time.November, 2, 13, 0)), at(2024, time.November, 3, 12, 0); !got.Equal(want) {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"@every",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("spec %q: expected error", spec)
+		}
+	}
+}
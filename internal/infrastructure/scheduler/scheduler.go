@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/jobqueue"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	jobPrefix     = "cron."
+	checkInterval = 15 * time.Second
+	recordTimeout = 5 * time.Second
+)
+
+type Store interface {
+	RegisterScheduledTask(ctx context.Context, name, spec string, nextRunAt time.Time) error
+	GetScheduledTasks(ctx context.Context) ([]models.ScheduledTask, error)
+	AdvanceScheduledTask(ctx context.Context, name string, prev, next time.Time) (bool, error)
+	RecordScheduledTaskRun(ctx context.Context, name string, startedAt time.Time, duration time.Duration, lastErr string) error
+}
+
+type Task struct {
+	Name string
+	Spec string
+	Run  func(ctx context.Context) error
+}
+
+type entry struct {
+	spec     string
+	schedule *Schedule
+}
+
+type Scheduler struct {
+	store   Store
+	queue   *jobqueue.Queue
+	leader  jobqueue.Leader
+	logger  *zap.Logger
+	entries map[string]entry
+}
+
+func New(store Store, queue *jobqueue.Queue, leader jobqueue.Leader, logger *zap.Logger) *Scheduler {
+	return &Scheduler{
+		store:   store,
+		queue:   queue,
+		leader:  leader,
+		logger:  logger,
+		entries: make(map[string]entry),
+	}
+}
+
+func (s *Scheduler) Add(t Task) error {
+	schedule, err := Parse(t.Spec)
+	if err != nil {
+		return fmt.Errorf("failed to add task %s: %w", t.Name, err)
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return fmt.Errorf("failed to add task %s: cron spec %q never matches", t.Name, t.Spec)
+	}
+	s.entries[t.Name] = entry{spec: t.Spec, schedule: schedule}
+
+	s.queue.Register(jobPrefix+t.Name, func(ctx context.Context, _ *models.Job) error {
+		started := time.Now()
+		err := t.Run(ctx)
+
+		var lastErr string
+		if err != nil {
+			lastErr = err.Error()
+		}
+		recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+		defer cancel()
+		if rerr := s.store.RecordScheduledTaskRun(recordCtx, t.Name, started, time.Since(started), lastErr); rerr != nil {
+			s.logger.Warn("failed to record scheduled task run", zap.String("task", t.Name), zap.Error(rerr))
+		}
+		return err
+	})
+	return nil
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	now := time.Now()
+	for name, e := range s.entries {
+		if err := s.store.RegisterScheduledTask(ctx, name, e.spec, e.schedule.Next(now)); err != nil {
+			s.logger.Error("failed to register scheduled task", zap.String("task", name), zap.Error(err))
+		}
+	}
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		if s.leader == nil || s.leader.IsLeader() {
+			s.dispatchDue(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) dispatchDue(ctx context.Context) {
+	tasks, err := s.store.GetScheduledTasks(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Warn("failed to get scheduled tasks", zap.Error(err))
+		}
+		return
+	}
+
+	now := time.Now()
+	for _, t := range tasks {
+		e, ok := s.entries[t.Name]
+		if !ok || t.NextRunAt.After(now) {
+			continue
+		}
+
+		advanced, err := s.store.AdvanceScheduledTask(ctx, t.Name, t.NextRunAt, e.schedule.Next(now))
+		if err != nil {
+			s.logger.Warn("failed to advance scheduled task", zap.String("task", t.Name), zap.Error(err))
+			continue
+		}
+		if !advanced {
+			continue
+		}
+
+		if _, err = s.queue.Enqueue(ctx, jobPrefix+t.Name, t.Name, nil, 0, now); err != nil {
+			s.logger.Warn("failed to enqueue scheduled task", zap.String("task", t.Name), zap.Error(err))
+			continue
+		}
+		s.logger.Debug("scheduled task enqueued", zap.String("task", t.Name))
+	}
+}
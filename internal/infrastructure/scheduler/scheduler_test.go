@@ -0,0 +1,26 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/jobqueue"
+	"go.uber.org/zap"
+)
+
+func TestAddRejectsNeverMatchingSpec(t *testing.T) {
+	s := New(nil, jobqueue.New(nil, zap.NewNop(), jobqueue.Options{}), nil, zap.NewNop())
+	run := func(context.Context) error { return nil }
+
+	for _, spec := range []string{"0 0 30 2 *", "0 0 31 4 *"} {
+		if err := s.Add(Task{Name: "test", Spec: spec, Run: run}); err == nil {
+			t.Errorf("spec %q: expected error", spec)
+		}
+	}
+	if err := s.Add(Task{Name: "test", Spec: "0 0 29 2 *", Run: run}); err != nil {
+		t.Errorf("leap day spec: %v", err)
+	}
+	if len(s.entries) != 1 {
+		t.Errorf("got %d entries, want 1", len(s.entries))
+	}
+}
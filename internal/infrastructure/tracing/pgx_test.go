@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestQueryTracerCreatesChildSpans(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := Install(exp, "test")
+	defer tp.Shutdown(context.Background())
+
+	ctx, parent := Start(context.Background(), "parent")
+	var qt QueryTracer
+
+	qctx := qt.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT id\n\t\tFROM orders WHERE number = $1"})
+	qt.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{})
+
+	qctx = qt.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "update orders set status = 'NEW'"})
+	qt.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{Err: errors.New("boom")})
+
+	qctx = qt.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	qt.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{Err: pgx.ErrNoRows})
+	parent.End()
+
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 4 {
+		t.Fatalf("got %d spans, want 4", len(spans))
+	}
+
+	parentID := parent.SpanContext().SpanID()
+	want := []struct {
+		name   string
+		query  string
+		status codes.Code
+	}{
+		{"db SELECT", "SELECT id FROM orders WHERE number = $1", codes.Unset},
+		{"db UPDATE", "update orders set status = 'NEW'", codes.Error},
+		{"db SELECT", "SELECT 1", codes.Unset},
+	}
+	for i, w := range want {
+		s := spans[i]
+		if s.Name != w.name {
+			t.Errorf("span %d: name %q, want %q", i, s.Name, w.name)
+		}
+		if s.Parent.SpanID() != parentID {
+			t.Errorf("span %d: parent %s, want %s", i, s.Parent.SpanID(), parentID)
+		}
+		if s.Status.Code != w.status {
+			t.Errorf("span %d: status %v, want %v", i, s.Status.Code, w.status)
+		}
+		var query string
+		for _, a := range s.Attributes {
+			if a.Key == semconv.DBQueryTextKey {
+				query = a.Value.AsString()
+			}
+		}
+		if query != w.query {
+			t.Errorf("span %d: query %q, want %q", i, query, w.query)
+		}
+	}
+}
+
+func TestQueryTracerSkipsWithoutParent(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := Install(exp, "test")
+	defer tp.Shutdown(context.Background())
+
+	var qt QueryTracer
+	ctx := qt.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	qt.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if spans := exp.GetSpans(); len(spans) != 0 {
+		t.Fatalf("got %d spans, want none", len(spans))
+	}
+}
@@ -1,46 +1,243 @@
 package worker
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-type Task func()
+var (
+	ErrPoolStopped = errors.New("worker pool is stopped")
+	ErrPoolFull    = errors.New("worker pool is full")
+)
+
+type Task func(ctx context.Context) error
+
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v", e.Value)
+}
+
+type Stats struct {
+	Workers    int
+	Busy       int
+	Queued     int
+	Completed  uint64
+	Failed     uint64
+	Panicked   uint64
+	AvgLatency time.Duration
+	MaxLatency time.Duration
+}
+
+type task struct {
+	ctx    context.Context
+	fn     Task
+	result chan error
+}
 
 type WorkerPool struct {
-	numWorkers int
-	queue      chan Task
-	wg         sync.WaitGroup
+	queue chan task
+	quit  chan struct{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	mu      sync.RWMutex
+	stopped bool
+
+	sizeMu   sync.Mutex
+	workers  int
+	quitting int
+
+	busy         atomic.Int64
+	completed    atomic.Uint64
+	failed       atomic.Uint64
+	panicked     atomic.Uint64
+	totalLatency atomic.Int64
+	maxLatency   atomic.Int64
 }
 
-func NewWorkerPool(limit int) *WorkerPool {
-	numWorkers := limit
-	if numWorkers <= 0 {
-		numWorkers = 1
+func NewWorkerPool(size, queueSize int) *WorkerPool {
+	if size <= 0 {
+		size = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
 	}
 
-	return &WorkerPool{
-		numWorkers: numWorkers,
-		queue:      make(chan Task, numWorkers),
+	p := &WorkerPool{
+		queue: make(chan task, queueSize),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
 	}
+	p.Resize(size)
+	return p
 }
 
-func (p *WorkerPool) Start() {
-	for i := 0; i < p.numWorkers; i++ {
+func (p *WorkerPool) Submit(ctx context.Context, fn Task) (<-chan error, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return nil, ErrPoolStopped
+	}
+
+	t := task{ctx: ctx, fn: fn, result: make(chan error, 1)}
+	select {
+	case p.queue <- t:
+		return t.result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *WorkerPool) TrySubmit(ctx context.Context, fn Task) (<-chan error, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return nil, ErrPoolStopped
+	}
+
+	t := task{ctx: ctx, fn: fn, result: make(chan error, 1)}
+	select {
+	case p.queue <- t:
+		return t.result, nil
+	default:
+		return nil, ErrPoolFull
+	}
+}
+
+func (p *WorkerPool) Resize(size int) {
+	if size <= 0 {
+		size = 1
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return
+	}
+
+	p.sizeMu.Lock()
+	defer p.sizeMu.Unlock()
+
+	for ; p.workers-p.quitting < size; p.workers++ {
 		p.wg.Add(1)
+		go p.work()
+	}
+	for ; p.workers-p.quitting > size; p.quitting++ {
 		go func() {
-			defer p.wg.Done()
-			for task := range p.queue {
-				task()
+			select {
+			case p.quit <- struct{}{}:
+			case <-p.done:
 			}
 		}()
 	}
 }
 
-func (p *WorkerPool) Submit(t Task) {
-	p.queue <- t
-}
-
 func (p *WorkerPool) Stop() {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return
+	}
+	p.stopped = true
 	close(p.queue)
+	p.mu.Unlock()
+
 	p.wg.Wait()
+	close(p.done)
+}
+
+func (p *WorkerPool) Stats() Stats {
+	p.sizeMu.Lock()
+	workers := p.workers
+	p.sizeMu.Unlock()
+
+	completed, failed := p.completed.Load(), p.failed.Load()
+	var avg time.Duration
+	if n := completed + failed; n > 0 {
+		avg = time.Duration(p.totalLatency.Load() / int64(n))
+	}
+
+	return Stats{
+		Workers:    workers,
+		Busy:       int(p.busy.Load()),
+		Queued:     len(p.queue),
+		Completed:  completed,
+		Failed:     failed,
+		Panicked:   p.panicked.Load(),
+		AvgLatency: avg,
+		MaxLatency: time.Duration(p.maxLatency.Load()),
+	}
+}
+
+func (p *WorkerPool) work() {
+	defer p.wg.Done()
+	for {
+		select {
+		case t, ok := <-p.queue:
+			if !ok {
+				p.exit(false)
+				return
+			}
+			p.run(t)
+		case <-p.quit:
+			p.exit(true)
+			return
+		}
+	}
+}
+
+func (p *WorkerPool) exit(quit bool) {
+	p.sizeMu.Lock()
+	defer p.sizeMu.Unlock()
+	p.workers--
+	if quit {
+		p.quitting--
+	}
+}
+
+func (p *WorkerPool) run(t task) {
+	p.busy.Add(1)
+	start := time.Now()
+
+	err := p.call(t)
+
+	latency := int64(time.Since(start))
+	p.totalLatency.Add(latency)
+	for {
+		cur := p.maxLatency.Load()
+		if latency <= cur || p.maxLatency.CompareAndSwap(cur, latency) {
+			break
+		}
+	}
+	if err != nil {
+		p.failed.Add(1)
+	} else {
+		p.completed.Add(1)
+	}
+	p.busy.Add(-1)
+
+	t.result <- err
+}
+
+func (p *WorkerPool) call(t task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.panicked.Add(1)
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	if err = t.ctx.Err(); err != nil {
+		return err
+	}
+	return t.fn(t.ctx)
 }
@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func blockingTask(started chan<- struct{}, release <-chan struct{}) Task {
+	return func(context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+}
+
+func TestWorkerPoolSurvivesPanic(t *testing.T) {
+	p := NewWorkerPool(1, 0)
+	defer p.Stop()
+	ctx := context.Background()
+
+	res, err := p.Submit(ctx, func(context.Context) error { panic("boom") })
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	var panicked *PanicError
+	if err = <-res; !errors.As(err, &panicked) || panicked.Value != "boom" || len(panicked.Stack) == 0 {
+		t.Fatalf("got %v, want PanicError with value and stack", err)
+	}
+
+	res, err = p.Submit(ctx, func(context.Context) error { return nil })
+	if err != nil {
+		t.Fatalf("submit after panic: %v", err)
+	}
+	if err = <-res; err != nil {
+		t.Fatalf("task after panic: %v", err)
+	}
+
+	st := p.Stats()
+	if st.Workers != 1 || st.Panicked != 1 || st.Failed != 1 || st.Completed != 1 {
+		t.Fatalf("stats %+v", st)
+	}
+}
+
+func TestWorkerPoolStopped(t *testing.T) {
+	p := NewWorkerPool(2, 0)
+	p.Stop()
+	p.Stop()
+
+	if _, err := p.Submit(context.Background(), func(context.Context) error { return nil }); !errors.Is(err, ErrPoolStopped) {
+		t.Fatalf("submit: got %v, want ErrPoolStopped", err)
+	}
+	if _, err := p.TrySubmit(context.Background(), func(context.Context) error { return nil }); !errors.Is(err, ErrPoolStopped) {
+		t.Fatalf("try submit: got %v, want ErrPoolStopped", err)
+	}
+	p.Resize(4)
+	if st := p.Stats(); st.Workers != 0 {
+		t.Fatalf("stopped pool has %d workers", st.Workers)
+	}
+}
+
+func TestWorkerPoolFull(t *testing.T) {
+	p := NewWorkerPool(1, 1)
+	defer p.Stop()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	ctx := context.Background()
+
+	if _, err := p.TrySubmit(ctx, blockingTask(started, release)); err != nil {
+		t.Fatalf("first: %v", err)
+	}
+	<-started
+	if _, err := p.TrySubmit(ctx, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("queued: %v", err)
+	}
+	if _, err := p.TrySubmit(ctx, func(context.Context) error { return nil }); !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("got %v, want ErrPoolFull", err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := p.Submit(cctx, func(context.Context) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("blocking submit with cancelled context: got %v", err)
+	}
+}
+
+func TestWorkerPoolResize(t *testing.T) {
+	p := NewWorkerPool(1, 0)
+	defer p.Stop()
+	ctx := context.Background()
+
+	p.Resize(4)
+	if st := p.Stats(); st.Workers != 4 {
+		t.Fatalf("after grow: %d workers, want 4", st.Workers)
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	for range 4 {
+		if _, err := p.Submit(ctx, blockingTask(started, release)); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+	}
+	for range 4 {
+		<-started
+	}
+
+	p.Resize(1)
+	if st := p.Stats(); st.Workers != 4 || st.Busy != 4 {
+		t.Fatalf("busy workers must keep running until they exit: %+v", st)
+	}
+
+	close(release)
+	waitFor(t, "shrink to 1 worker", func() bool { return p.Stats().Workers == 1 })
+
+	p.Resize(3)
+	p.Resize(2)
+	waitFor(t, "settle at 2 workers", func() bool { return p.Stats().Workers == 2 })
+
+	started, release = make(chan struct{}), make(chan struct{})
+	for range 2 {
+		if _, err := p.Submit(ctx, blockingTask(started, release)); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+	}
+	for range 2 {
+		<-started
+	}
+	if _, err := p.TrySubmit(ctx, func(context.Context) error { return nil }); !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("third task on 2 busy workers: got %v, want ErrPoolFull", err)
+	}
+	close(release)
+}
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
+	"slices"
 	"time"
 )
 
@@ -14,16 +16,83 @@ type User struct {
 	ID           int64     `json:"-"`
 	Login        string    `json:"login"`
 	PasswordHash []byte    `json:"-"`
+	Role         string    `json:"-"`
 	CreatedAt    time.Time `json:"-"`
 }
 
+type UserInfo struct {
+	ID        int64     `json:"id"`
+	Login     string    `json:"login"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Principal struct {
+	UserID   int64
+	Role     string
+	APIKeyID int64
+	Scopes   []string
+}
+
+func (p *Principal) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if p.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	if p.APIKeyID == 0 {
+		return true
+	}
+	return slices.Contains(p.Scopes, scope)
+}
+
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	UserIDs    []int64    `json:"user_ids"`
+	RateLimit  int        `json:"rate_limit"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type APIKeyReq struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	UserIDs   []int64  `json:"user_ids"`
+	RateLimit int      `json:"rate_limit"`
+}
+
+type APIKeyCreated struct {
+	APIKey
+	Key string `json:"key"`
+}
+
 type Order struct {
 	ID         int64     `json:"-"`
 	UserID     int64     `json:"-"`
 	Number     string    `json:"number"`
 	Status     string    `json:"status"`
 	Accrual    float64   `json:"accrual,omitempty"`
+	Provider   string    `json:"-"`
+	Attempts   int       `json:"-"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+type ParkedOrder struct {
+	Number     string    `json:"number"`
+	UserID     int64     `json:"user_id"`
+	Status     string    `json:"status"`
+	Provider   string    `json:"provider"`
+	Attempts   int       `json:"attempts"`
 	UploadedAt time.Time `json:"uploaded_at"`
+	ParkedAt   time.Time `json:"parked_at"`
 }
 
 type AccrualResp struct {
@@ -32,6 +101,59 @@ type AccrualResp struct {
 	Accrual float64 `json:"accrual,omitempty"`
 }
 
+type AccrualThrottledError struct {
+	RetryAfter     time.Duration
+	LimitPerMinute int
+}
+
+func (e *AccrualThrottledError) Error() string {
+	return ErrAccrualOrderTooMany.Error()
+}
+
+func (e *AccrualThrottledError) Unwrap() error {
+	return ErrAccrualOrderTooMany
+}
+
+type Discrepancy struct {
+	ID              int64      `json:"id"`
+	OrderID         int64      `json:"-"`
+	UserID          int64      `json:"user_id"`
+	Number          string     `json:"number"`
+	Provider        string     `json:"provider"`
+	ExpectedAccrual float64    `json:"expected_accrual"`
+	ReportedStatus  string     `json:"reported_status"`
+	ReportedAccrual float64    `json:"reported_accrual"`
+	Status          string     `json:"status"`
+	AdjustmentID    *int64     `json:"adjustment_id,omitempty"`
+	DetectedAt      time.Time  `json:"detected_at"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+}
+
+type Job struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Key         string          `json:"key,omitempty"`
+	Owner       string          `json:"owner,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Priority    int             `json:"priority"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	RunAt       time.Time       `json:"run_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type ScheduledTask struct {
+	Name         string     `json:"name"`
+	Spec         string     `json:"spec"`
+	NextRunAt    time.Time  `json:"next_run_at"`
+	LastRunAt    *time.Time `json:"last_run_at,omitempty"`
+	LastStatus   string     `json:"last_status,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	LastDuration float64    `json:"last_duration_sec,omitempty"`
+}
+
 type Withdrawal struct {
 	Order       string    `json:"order"`
 	Sum         float64   `json:"sum"`
@@ -43,6 +165,27 @@ type Balance struct {
 	Withdrawn float64 `json:"withdrawn"`
 }
 
+type Transaction struct {
+	Type        string    `json:"type"`
+	Order       string    `json:"order,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Amount      float64   `json:"amount"`
+	Balance     float64   `json:"balance"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type AdjustmentReq struct {
+	Amount float64 `json:"amount"`
+	Reason string  `json:"reason"`
+}
+
+type AuditRecord struct {
+	ActorID int64
+	Action  string
+	Target  string
+	Details map[string]any
+}
+
 type WithdrawReq struct {
 	Order string  `json:"order"`
 	Sum   float64 `json:"sum"`
@@ -54,6 +197,45 @@ const (
 	StatusProcessing = "PROCESSING"
 	StatusProcessed  = "PROCESSED"
 	StatusNew        = "NEW"
+
+	StatusNotRegistered = "NOT_REGISTERED"
+)
+
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+	RoleService = "service"
+)
+
+const (
+	ScopeOrdersRead   = "orders:read"
+	ScopeOrdersWrite  = "orders:write"
+	ScopeBalanceRead  = "balance:read"
+	ScopeBalanceWrite = "balance:write"
+)
+
+const (
+	JobPending = "PENDING"
+	JobRunning = "RUNNING"
+	JobDead    = "DEAD"
+)
+
+const (
+	TaskSucceeded = "SUCCEEDED"
+	TaskFailed    = "FAILED"
+)
+
+const (
+	DiscrepancyOpen      = "OPEN"
+	DiscrepancyCorrected = "CORRECTED"
+	DiscrepancyDismissed = "DISMISSED"
+)
+
+const (
+	TransactionAccrual    = "ACCRUAL"
+	TransactionWithdrawal = "WITHDRAWAL"
+	TransactionAdjustment = "ADJUSTMENT"
 )
 
 var (
@@ -65,9 +247,27 @@ var (
 	ErrOrderNotFound                  = errors.New("order not found")
 	ErrOrderBelongsToAnotherUser      = errors.New("order belongs to another user")
 	ErrOrderAlreadyUploadedBySameUser = errors.New("order already uploaded by same user")
+	ErrOrderLeaseLost                 = errors.New("order lease lost")
+	ErrOrderAlreadyProcessed          = errors.New("order already processed")
+
+	ErrJobLeaseLost = errors.New("job lease lost")
 
 	ErrAccrualOrderNotRegistered = errors.New("order not registered in accrual system")
 	ErrAccrualOrderTooMany       = errors.New("too many requests to accrual system")
+	ErrAccrualUnavailable        = errors.New("accrual system unavailable")
+	ErrAccrualStatusInvalid      = errors.New("invalid accrual status")
+	ErrAccrualBatchUnsupported   = errors.New("accrual batch lookup unsupported")
+
+	ErrAdjustmentInvalid        = errors.New("invalid adjustment")
+	ErrAdjustmentExceedsBalance = errors.New("adjustment exceeds balance")
+
+	ErrDiscrepancyNotFound = errors.New("discrepancy not found")
+	ErrDiscrepancyResolved = errors.New("discrepancy already resolved")
+
+	ErrAPIKeyInvalid     = errors.New("invalid api key")
+	ErrAPIKeyNotFound    = errors.New("api key not found")
+	ErrAPIKeyRateLimited = errors.New("api key rate limit exceeded")
+	ErrAPIKeyReqInvalid  = errors.New("invalid api key request")
 
 	ErrWithdrawalOrderExists = errors.New("order already exists")
 	ErrPaymentRequired       = errors.New("payment required")
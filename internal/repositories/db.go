@@ -5,9 +5,14 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/configs"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/tracing"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
@@ -25,17 +30,26 @@ func NewDB(ctx context.Context, cfg *configs.ServerConfig, logger *zap.Logger) (
 		return nil, errors.New("database URI is not set")
 	}
 
-	logger.Info("initializing database storage", zap.String("URI", cfg.DatabaseURI))
+	poolCfg, err := pgxpool.ParseConfig(cfg.DatabaseURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database URI: %w", err)
+	}
+
+	logger.Info("initializing database storage",
+		zap.String("host", poolCfg.ConnConfig.Host),
+		zap.Uint16("port", poolCfg.ConnConfig.Port),
+		zap.String("database", poolCfg.ConnConfig.Database),
+	)
 
 	logger.Debug("running database migrations")
-	err := runMigrations(cfg)
+	err = runMigrations(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to run database migrations: %w", err)
 	}
 	logger.Debug("database migrations completed")
 
 	logger.Debug("connecting to database")
-	pool, err := initPool(ctx, cfg)
+	pool, err := initPool(ctx, poolCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -68,11 +82,8 @@ func runMigrations(cfg *configs.ServerConfig) error {
 	return nil
 }
 
-func initPool(ctx context.Context, cfg *configs.ServerConfig) (*pgxpool.Pool, error) {
-	poolCfg, err := pgxpool.ParseConfig(cfg.DatabaseURI)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse database URI %s: %w", cfg.DatabaseURI, err)
-	}
+func initPool(ctx context.Context, poolCfg *pgxpool.Config) (*pgxpool.Pool, error) {
+	poolCfg.ConnConfig.Tracer = tracing.QueryTracer{}
 
 	ctxTimeout, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
@@ -89,6 +100,57 @@ func initPool(ctx context.Context, cfg *configs.ServerConfig) (*pgxpool.Pool, er
 	return pool, nil
 }
 
+func (db *DB) Ping(ctx context.Context) error {
+	if err := db.pool.Ping(ctx); err != nil {
+		return fmt.Errorf("database error: failed to ping: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) CheckMigrations(ctx context.Context) error {
+	want, err := latestMigration()
+	if err != nil {
+		return err
+	}
+
+	var (
+		version uint
+		dirty   bool
+	)
+	if err = db.pool.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty); err != nil {
+		return fmt.Errorf("database error: failed to get migration version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	if version != want {
+		return fmt.Errorf("migration version %d, expected %d", version, want)
+	}
+	return nil
+}
+
+func latestMigration() (uint, error) {
+	names, err := fs.Glob(migrationsDir, "migrations/*.up.sql")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	var latest uint
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(path.Base(name), "_")
+		v, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration file name %q: %w", name, err)
+		}
+		latest = max(latest, uint(v))
+	}
+	return latest, nil
+}
+
+func (db *DB) Stat() *pgxpool.Stat {
+	return db.pool.Stat()
+}
+
 func (db *DB) Close() {
 	db.pool.Close()
 }
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/configs"
+	"go.uber.org/zap"
+)
+
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	uri := os.Getenv("DATABASE_URI")
+	if uri == "" {
+		t.Skip("DATABASE_URI is not set")
+	}
+
+	db, err := NewDB(context.Background(), &configs.ServerConfig{DatabaseURI: uri}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("connect to database: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+func testSuffix() string {
+	return fmt.Sprintf("%d", time.Now().UnixNano())
+}
+
+func createTestUser(t *testing.T, db *DB) int64 {
+	t.Helper()
+
+	ctx := context.Background()
+	id, err := db.CreateUser(ctx, "test-"+testSuffix(), []byte("hash"))
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := db.pool.Exec(context.Background(), `DELETE FROM users WHERE id = $1`, id); err != nil {
+			t.Errorf("delete user: %v", err)
+		}
+	})
+	return id
+}
+
+func createTestOrders(t *testing.T, db *DB, userID int64, provider string, n int) []string {
+	t.Helper()
+
+	prefix := testSuffix()
+	numbers := make([]string, n)
+	for i := range numbers {
+		numbers[i] = fmt.Sprintf("%s%04d", prefix, i)
+		if err := db.InsertOrder(context.Background(), userID, numbers[i], provider); err != nil {
+			t.Fatalf("insert order: %v", err)
+		}
+	}
+	return numbers
+}
@@ -0,0 +1,9 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS idx_adjustments_user_created_at;
+
+DROP TABLE IF EXISTS adjustments;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS processed_at;
+
+COMMIT;
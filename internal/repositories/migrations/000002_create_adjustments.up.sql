@@ -0,0 +1,16 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS processed_at TIMESTAMPTZ;
+UPDATE orders SET processed_at = uploaded_at WHERE status = 'PROCESSED' AND processed_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS adjustments
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    amount     NUMERIC(20, 2) NOT NULL CHECK (amount <> 0),
+    reason     TEXT           NOT NULL,
+    created_at TIMESTAMPTZ    NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_adjustments_user_created_at ON adjustments (user_id, created_at DESC);
+
+COMMIT;
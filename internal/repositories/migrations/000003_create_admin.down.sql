@@ -0,0 +1,12 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS idx_audit_log_created_at;
+DROP INDEX IF EXISTS idx_audit_log_actor_created_at;
+
+DROP TABLE IF EXISTS audit_log;
+
+ALTER TABLE adjustments DROP COLUMN IF EXISTS created_by;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+
+COMMIT;
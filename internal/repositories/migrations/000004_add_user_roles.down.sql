@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+UPDATE users SET role = 'user' WHERE role IN ('support', 'service');
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'));
+
+COMMIT;
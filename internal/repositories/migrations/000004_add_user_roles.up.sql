@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'support', 'admin', 'service'));
+
+COMMIT;
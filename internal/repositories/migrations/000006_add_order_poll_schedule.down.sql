@@ -0,0 +1,11 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS idx_orders_parked;
+DROP INDEX IF EXISTS idx_orders_poll;
+CREATE INDEX IF NOT EXISTS idx_orders_poll ON orders (status, uploaded_at) WHERE status IN ('NEW', 'PROCESSING');
+
+ALTER TABLE orders DROP COLUMN IF EXISTS parked_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS attempts;
+ALTER TABLE orders DROP COLUMN IF EXISTS next_poll_at;
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS next_poll_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS parked_at TIMESTAMPTZ;
+
+DROP INDEX IF EXISTS idx_orders_poll;
+CREATE INDEX IF NOT EXISTS idx_orders_poll ON orders (next_poll_at) WHERE status IN ('NEW', 'PROCESSING') AND parked_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_orders_parked ON orders (parked_at) WHERE parked_at IS NOT NULL;
+
+COMMIT;
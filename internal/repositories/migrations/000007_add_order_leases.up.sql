@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS claimed_by TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS lease_until TIMESTAMPTZ;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS accrual_discrepancies;
+
+DROP INDEX IF EXISTS idx_orders_processed_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS reconciled_at;
+
+COMMIT;
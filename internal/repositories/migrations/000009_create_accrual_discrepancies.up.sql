@@ -0,0 +1,22 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS reconciled_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_orders_processed_at ON orders (processed_at) WHERE status = 'PROCESSED';
+
+CREATE TABLE IF NOT EXISTS accrual_discrepancies
+(
+    id               BIGSERIAL PRIMARY KEY,
+    order_id         BIGINT         NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    expected_accrual NUMERIC(20, 2) NOT NULL,
+    reported_status  TEXT           NOT NULL,
+    reported_accrual NUMERIC(20, 2) NOT NULL,
+    status           TEXT           NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'CORRECTED', 'DISMISSED')),
+    adjustment_id    BIGINT REFERENCES adjustments (id) ON DELETE SET NULL,
+    resolved_by      BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    detected_at      TIMESTAMPTZ    NOT NULL DEFAULT NOW(),
+    resolved_at      TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accrual_discrepancies_open ON accrual_discrepancies (order_id) WHERE status = 'OPEN';
+CREATE INDEX IF NOT EXISTS idx_accrual_discrepancies_status_detected_at ON accrual_discrepancies (status, detected_at DESC);
+
+COMMIT;
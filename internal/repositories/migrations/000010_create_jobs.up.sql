@@ -0,0 +1,25 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS jobs
+(
+    id           BIGSERIAL PRIMARY KEY,
+    type         TEXT        NOT NULL,
+    key          TEXT,
+    owner        TEXT,
+    payload      JSONB       NOT NULL DEFAULT '{}',
+    priority     INT         NOT NULL DEFAULT 0,
+    status       TEXT        NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'RUNNING', 'DEAD')),
+    attempts     INT         NOT NULL DEFAULT 0,
+    max_attempts INT         NOT NULL DEFAULT 5,
+    last_error   TEXT,
+    run_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_by    TEXT,
+    locked_until TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_type_key ON jobs (type, key) WHERE key IS NOT NULL AND status IN ('PENDING', 'RUNNING');
+CREATE INDEX IF NOT EXISTS idx_jobs_ready ON jobs (priority DESC, run_at) WHERE status IN ('PENDING', 'RUNNING');
+CREATE INDEX IF NOT EXISTS idx_jobs_dead ON jobs (updated_at DESC) WHERE status = 'DEAD';
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS scheduled_tasks
+(
+    name          TEXT PRIMARY KEY,
+    spec          TEXT        NOT NULL,
+    next_run_at   TIMESTAMPTZ NOT NULL,
+    last_run_at   TIMESTAMPTZ,
+    last_status   TEXT CHECK (last_status IN ('SUCCEEDED', 'FAILED')),
+    last_error    TEXT,
+    last_duration DOUBLE PRECISION,
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
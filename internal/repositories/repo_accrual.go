@@ -4,33 +4,43 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
-	"github.com/jackc/pgx/v5"
 )
 
-func (db *DB) SelectOrdersForAccrualPollingTx(ctx context.Context, tx pgx.Tx, limit int) ([]models.Order, error) {
+func (db *DB) ClaimOrdersForAccrualPolling(ctx context.Context, owner string, providers []string, limit int, ttl time.Duration) ([]models.Order, error) {
 	query := `
-		SELECT id, user_id, number, status, accrual, uploaded_at
-		FROM orders
-		WHERE status IN ('NEW', 'PROCESSING')
-		ORDER BY uploaded_at
-		FOR UPDATE SKIP LOCKED
-		LIMIT $1
+		WITH c AS (
+			SELECT id
+			FROM orders
+			WHERE status IN ('NEW', 'PROCESSING') AND parked_at IS NULL AND next_poll_at <= NOW()
+				AND (lease_until IS NULL OR lease_until < NOW()) AND provider = ANY($4)
+			ORDER BY next_poll_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE orders o
+		SET claimed_by = $1,
+			lease_until = NOW() + make_interval(secs => $3),
+			status = CASE WHEN o.status = 'NEW' THEN 'PROCESSING' ELSE o.status END
+		FROM c
+		WHERE o.id = c.id
+		RETURNING o.id, o.user_id, o.number, o.status, o.accrual, o.provider, o.attempts, o.uploaded_at
 `
-	rows, err := tx.Query(ctx, query, limit)
+	rows, err := db.pool.Query(ctx, query, owner, limit, ttl.Seconds(), providers)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
 			return nil, err
 		}
-		return nil, fmt.Errorf("database error: failed to list orders for accrual polling: %w", err)
+		return nil, fmt.Errorf("database error: failed to claim orders for accrual polling: %w", err)
 	}
 	defer rows.Close()
 
 	var orders []models.Order
 	for rows.Next() {
 		var order models.Order
-		if err = rows.Scan(&order.ID, &order.UserID, &order.Number, &order.Status, &order.Accrual, &order.UploadedAt); err != nil {
+		if err = rows.Scan(&order.ID, &order.UserID, &order.Number, &order.Status, &order.Accrual, &order.Provider, &order.Attempts, &order.UploadedAt); err != nil {
 			return nil, fmt.Errorf("database error: failed to scan order: %w", err)
 		}
 		orders = append(orders, order)
@@ -43,49 +53,159 @@ func (db *DB) SelectOrdersForAccrualPollingTx(ctx context.Context, tx pgx.Tx, li
 	return orders, nil
 }
 
-func (db *DB) MarkOrdersProcessingTx(ctx context.Context, tx pgx.Tx, ids []int64) error {
+func (db *DB) ExtendOrderLeases(ctx context.Context, owner string, ids []int64, ttl time.Duration) error {
 	if len(ids) == 0 {
 		return nil
 	}
 
-	query := `UPDATE orders SET status = 'PROCESSING' WHERE id = ANY($1) AND status='NEW'`
-
-	_, err := tx.Exec(ctx, query, ids)
-	if err != nil {
+	query := `
+		UPDATE orders
+		SET lease_until = NOW() + make_interval(secs => $3)
+		WHERE id = ANY($2) AND claimed_by = $1
+	`
+	if _, err := db.pool.Exec(ctx, query, owner, ids, ttl.Seconds()); err != nil {
 		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
 			return err
 		}
-		return fmt.Errorf("database error: failed to mark orders processing: %w", err)
+		return fmt.Errorf("database error: failed to extend order leases: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) ReleaseOrderLeases(ctx context.Context, owner string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
 	}
 
+	query := `
+		UPDATE orders
+		SET claimed_by = NULL, lease_until = NULL
+		WHERE id = ANY($2) AND claimed_by = $1
+	`
+	if _, err := db.pool.Exec(ctx, query, owner, ids); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return err
+		}
+		return fmt.Errorf("database error: failed to release order leases: %w", err)
+	}
 	return nil
 }
 
-func (db *DB) UpdateOrderStatusAndAccrual(ctx context.Context, accrualResp *models.AccrualResp) error {
-	var (
-		query string
-		args  []any
-	)
+func (db *DB) ApplyOrderAccrual(ctx context.Context, owner string, accrualResp *models.AccrualResp) (bool, error) {
+	query := `
+		UPDATE orders
+		SET status = $2,
+			accrual = $3,
+			processed_at = CASE WHEN $2 = 'PROCESSED' THEN NOW() END,
+			parked_at = CASE WHEN $2 IN ('PROCESSED', 'INVALID') THEN NULL ELSE parked_at END,
+			claimed_by = CASE WHEN $2 IN ('PROCESSED', 'INVALID') THEN NULL ELSE claimed_by END,
+			lease_until = CASE WHEN $2 IN ('PROCESSED', 'INVALID') THEN NULL ELSE lease_until END
+		WHERE number = $1 AND status IN ('NEW', 'PROCESSING') AND ($4 = '' OR claimed_by = $4)
+	`
+	ct, err := db.pool.Exec(ctx, query, accrualResp.Order, accrualResp.Status, accrualResp.Accrual, owner)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return false, err
+		}
+		return false, fmt.Errorf("database error: failed to apply order accrual: %w", err)
+	}
+	if ct.RowsAffected() > 0 {
+		return true, nil
+	}
+	if owner != "" {
+		return false, models.ErrOrderLeaseLost
+	}
 
-	if accrualResp.Status == models.StatusProcessed {
-		query = `UPDATE orders SET status = $1, accrual = $2 WHERE number = $3`
-		args = []any{accrualResp.Status, accrualResp.Accrual, accrualResp.Order}
-	} else {
-		query = `UPDATE orders SET status = $1 WHERE number = $2`
-		args = []any{accrualResp.Status, accrualResp.Order}
+	var exists bool
+	if err = db.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM orders WHERE number = $1)`, accrualResp.Order).Scan(&exists); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return false, err
+		}
+		return false, fmt.Errorf("database error: failed to check order existence: %w", err)
+	}
+	if !exists {
+		return false, models.ErrOrderNotFound
 	}
+	return false, nil
+}
+
+func (db *DB) ScheduleOrderPoll(ctx context.Context, owner string, id int64, attempts int, nextPollAt time.Time) error {
+	query := `
+		UPDATE orders
+		SET attempts = $3, next_poll_at = $4, claimed_by = NULL, lease_until = NULL
+		WHERE id = $2 AND claimed_by = $1
+	`
+	return db.execLeased(ctx, "schedule order poll", query, owner, id, attempts, nextPollAt)
+}
+
+func (db *DB) ParkOrder(ctx context.Context, owner string, id int64, attempts int) error {
+	query := `
+		UPDATE orders
+		SET attempts = $3, parked_at = NOW(), claimed_by = NULL, lease_until = NULL
+		WHERE id = $2 AND claimed_by = $1
+	`
+	return db.execLeased(ctx, "park order", query, owner, id, attempts)
+}
 
+func (db *DB) execLeased(ctx context.Context, op, query string, args ...any) error {
 	ct, err := db.pool.Exec(ctx, query, args...)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
 			return err
 		}
-		return fmt.Errorf("database error: failed to update order status and accrual: %w", err)
+		return fmt.Errorf("database error: failed to %s: %w", op, err)
 	}
 
 	if ct.RowsAffected() == 0 {
-		return pgx.ErrNoRows
+		return models.ErrOrderLeaseLost
 	}
-
 	return nil
 }
+
+func (db *DB) ReassignOrderProviders(ctx context.Context, known []string, fallback string) (int64, error) {
+	query := `
+		UPDATE orders
+		SET provider = $2
+		WHERE status IN ('NEW', 'PROCESSING') AND NOT (provider = ANY($1))
+	`
+	ct, err := db.pool.Exec(ctx, query, known, fallback)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("database error: failed to reassign order providers: %w", err)
+	}
+	return ct.RowsAffected(), nil
+}
+
+func (db *DB) GetParkedOrders(ctx context.Context, limit int) ([]models.ParkedOrder, error) {
+	query := `
+		SELECT number, user_id, status, provider, attempts, uploaded_at, parked_at
+		FROM orders
+		WHERE parked_at IS NOT NULL
+		ORDER BY parked_at DESC
+		LIMIT $1
+`
+	rows, err := db.pool.Query(ctx, query, limit)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("database error: failed to list parked orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []models.ParkedOrder
+	for rows.Next() {
+		var order models.ParkedOrder
+		if err = rows.Scan(&order.Number, &order.UserID, &order.Status, &order.Provider, &order.Attempts, &order.UploadedAt, &order.ParkedAt); err != nil {
+			return nil, fmt.Errorf("database error: failed to scan parked order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: failed to iterate over parked orders: %w", err)
+	}
+	return orders, nil
+}
@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+)
+
+func TestClaimOrdersConcurrentOwners(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	const total = 200
+	provider := "test-" + testSuffix()
+	createTestOrders(t, db, createTestUser(t, db), provider, total)
+
+	var (
+		mu      sync.Mutex
+		claimed = make(map[int64]string)
+		dup     []int64
+		wg      sync.WaitGroup
+	)
+	for _, owner := range []string{"owner-a", "owner-b"} {
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					orders, err := db.ClaimOrdersForAccrualPolling(ctx, owner, []string{provider}, 7, time.Minute)
+					if err != nil {
+						t.Errorf("claim: %v", err)
+						return
+					}
+					if len(orders) == 0 {
+						return
+					}
+					mu.Lock()
+					for _, o := range orders {
+						if _, ok := claimed[o.ID]; ok {
+							dup = append(dup, o.ID)
+						}
+						claimed[o.ID] = owner
+						if o.Status != models.StatusProcessing {
+							t.Errorf("claimed order %d has status %s", o.ID, o.Status)
+						}
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if len(dup) > 0 {
+		t.Fatalf("orders claimed more than once: %v", dup)
+	}
+	if len(claimed) != total {
+		t.Fatalf("claimed %d orders, want %d", len(claimed), total)
+	}
+}
+
+func TestApplyOrderAccrualAfterLeaseTakeover(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	provider := "test-" + testSuffix()
+	numbers := createTestOrders(t, db, createTestUser(t, db), provider, 1)
+
+	first, err := db.ClaimOrdersForAccrualPolling(ctx, "owner-a", []string{provider}, 10, 200*time.Millisecond)
+	if err != nil || len(first) != 1 {
+		t.Fatalf("owner-a claim: %v, %d orders", err, len(first))
+	}
+
+	again, err := db.ClaimOrdersForAccrualPolling(ctx, "owner-b", []string{provider}, 10, time.Minute)
+	if err != nil || len(again) != 0 {
+		t.Fatalf("owner-b claimed a live lease: %v, %d orders", err, len(again))
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	second, err := db.ClaimOrdersForAccrualPolling(ctx, "owner-b", []string{provider}, 10, time.Minute)
+	if err != nil || len(second) != 1 || second[0].ID != first[0].ID {
+		t.Fatalf("owner-b takeover: %v, %v", err, second)
+	}
+
+	id := first[0].ID
+	resp := &models.AccrualResp{Order: numbers[0], Status: models.StatusProcessed, Accrual: 100}
+	if _, err = db.ApplyOrderAccrual(ctx, "owner-a", resp); !errors.Is(err, models.ErrOrderLeaseLost) {
+		t.Fatalf("owner-a finish: got %v, want ErrOrderLeaseLost", err)
+	}
+	if err = db.ScheduleOrderPoll(ctx, "owner-a", id, 1, time.Now()); !errors.Is(err, models.ErrOrderLeaseLost) {
+		t.Fatalf("owner-a schedule: got %v, want ErrOrderLeaseLost", err)
+	}
+	if err = db.ParkOrder(ctx, "owner-a", id, 1); !errors.Is(err, models.ErrOrderLeaseLost) {
+		t.Fatalf("owner-a park: got %v, want ErrOrderLeaseLost", err)
+	}
+
+	resp.Accrual = 42
+	if _, err = db.ApplyOrderAccrual(ctx, "owner-b", resp); err != nil {
+		t.Fatalf("owner-b finish: %v", err)
+	}
+
+	var (
+		status  string
+		accrual float64
+	)
+	if err = db.pool.QueryRow(ctx, `SELECT status, accrual FROM orders WHERE id = $1`, id).Scan(&status, &accrual); err != nil {
+		t.Fatalf("read order: %v", err)
+	}
+	if status != models.StatusProcessed || accrual != 42 {
+		t.Fatalf("order is %s/%v, want PROCESSED/42", status, accrual)
+	}
+}
+
+func TestCallbackAndPollApplyOnce(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	provider := "test-" + testSuffix()
+	numbers := createTestOrders(t, db, createTestUser(t, db), provider, 1)
+
+	claimed, err := db.ClaimOrdersForAccrualPolling(ctx, "owner-a", []string{provider}, 10, time.Minute)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("claim: %v, %d orders", err, len(claimed))
+	}
+
+	applied, err := db.ApplyOrderAccrual(ctx, "", &models.AccrualResp{Order: numbers[0], Status: models.StatusProcessed, Accrual: 100})
+	if err != nil || !applied {
+		t.Fatalf("callback: applied %v, err %v", applied, err)
+	}
+
+	_, err = db.ApplyOrderAccrual(ctx, "owner-a", &models.AccrualResp{Order: numbers[0], Status: models.StatusProcessed, Accrual: 42})
+	if !errors.Is(err, models.ErrOrderLeaseLost) {
+		t.Fatalf("poll after callback: got %v, want ErrOrderLeaseLost", err)
+	}
+	applied, err = db.ApplyOrderAccrual(ctx, "", &models.AccrualResp{Order: numbers[0], Status: models.StatusProcessed, Accrual: 42})
+	if err != nil || applied {
+		t.Fatalf("repeated callback: applied %v, err %v", applied, err)
+	}
+
+	var (
+		accrual   float64
+		claimedBy *string
+	)
+	if err = db.pool.QueryRow(ctx, `SELECT accrual, claimed_by FROM orders WHERE number = $1`, numbers[0]).Scan(&accrual, &claimedBy); err != nil {
+		t.Fatalf("read order: %v", err)
+	}
+	if accrual != 100 || claimedBy != nil {
+		t.Fatalf("order has accrual %v and claimed_by %v, want 100 and NULL", accrual, claimedBy)
+	}
+}
@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (db *DB) ResetOrderForPollingTx(ctx context.Context, tx pgx.Tx, number string) (*models.Order, error) {
+	query := `
+		UPDATE orders
+		SET status = 'NEW', accrual = 0, processed_at = NULL, attempts = 0, next_poll_at = NOW(), parked_at = NULL,
+			claimed_by = NULL, lease_until = NULL
+		WHERE id = $1
+	`
+	return db.changeUnprocessedOrderTx(ctx, tx, "reset order for polling", query, number)
+}
+
+func (db *DB) InvalidateOrderTx(ctx context.Context, tx pgx.Tx, number string) (*models.Order, error) {
+	query := `
+		UPDATE orders
+		SET status = 'INVALID', accrual = 0, processed_at = NULL, parked_at = NULL, claimed_by = NULL, lease_until = NULL
+		WHERE id = $1
+	`
+	return db.changeUnprocessedOrderTx(ctx, tx, "invalidate order", query, number)
+}
+
+func (db *DB) changeUnprocessedOrderTx(ctx context.Context, tx pgx.Tx, op, query, number string) (*models.Order, error) {
+	qLock := `
+		SELECT id, user_id, number, status, accrual
+		FROM orders
+		WHERE number = $1
+		FOR UPDATE
+	`
+	var prev models.Order
+	err := tx.QueryRow(ctx, qLock, number).Scan(&prev.ID, &prev.UserID, &prev.Number, &prev.Status, &prev.Accrual)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("database error: failed to lock order: %w", err)
+	}
+
+	if prev.Status == models.StatusProcessed {
+		return nil, models.ErrOrderAlreadyProcessed
+	}
+
+	if _, err = tx.Exec(ctx, query, prev.ID); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("database error: failed to %s: %w", op, err)
+	}
+	return &prev, nil
+}
+
+func (db *DB) CreateAdjustmentTx(ctx context.Context, tx pgx.Tx, userID, actorID int64, adj *models.AdjustmentReq) (int64, error) {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, userID); err != nil {
+		return 0, fmt.Errorf("database error: failed to acquire advisory lock: %w", err)
+	}
+
+	query := `
+		INSERT INTO adjustments (user_id, amount, reason, created_by)
+		VALUES ($1, $2, $3, NULLIF($4, 0))
+		RETURNING id
+	`
+	var id int64
+	if err := tx.QueryRow(ctx, query, userID, adj.Amount, adj.Reason, actorID).Scan(&id); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return 0, err
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return 0, models.ErrUserNotFound
+		}
+		return 0, fmt.Errorf("database error: failed to insert adjustment: %w", err)
+	}
+	return id, nil
+}
+
+func (db *DB) InsertAuditRecord(ctx context.Context, rec *models.AuditRecord) error {
+	return insertAuditRecord(ctx, db.pool, rec)
+}
+
+func (db *DB) InsertAuditRecordTx(ctx context.Context, tx pgx.Tx, rec *models.AuditRecord) error {
+	return insertAuditRecord(ctx, tx, rec)
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+func insertAuditRecord(ctx context.Context, e execer, rec *models.AuditRecord) error {
+	query := `
+		INSERT INTO audit_log (actor_id, action, target, details)
+		VALUES (NULLIF($1, 0), $2, $3, $4)
+	`
+	details := rec.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+
+	if _, err := e.Exec(ctx, query, rec.ActorID, rec.Action, rec.Target, details); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return err
+		}
+		return fmt.Errorf("database error: failed to insert audit record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DB) CreateAPIKeyTx(ctx context.Context, tx pgx.Tx, actorID int64, prefix string, keyHash []byte, req *models.APIKeyReq) (*models.APIKey, error) {
+	query := `
+		INSERT INTO api_keys (name, prefix, key_hash, scopes, user_ids, rate_limit, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, name, prefix, scopes, user_ids, rate_limit, created_at, last_used_at, revoked_at
+	`
+	key, err := scanAPIKey(tx.QueryRow(ctx, query, req.Name, prefix, keyHash, req.Scopes, req.UserIDs, req.RateLimit, actorID))
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("database error: failed to create api key: %w", err)
+	}
+	return key, nil
+}
+
+func (db *DB) UpdateAPIKeyTx(ctx context.Context, tx pgx.Tx, id int64, req *models.APIKeyReq) (*models.APIKey, error) {
+	query := `
+		UPDATE api_keys
+		SET name = $2, scopes = $3, user_ids = $4, rate_limit = $5
+		WHERE id = $1 AND revoked_at IS NULL
+		RETURNING id, name, prefix, scopes, user_ids, rate_limit, created_at, last_used_at, revoked_at
+	`
+	key, err := scanAPIKey(tx.QueryRow(ctx, query, id, req.Name, req.Scopes, req.UserIDs, req.RateLimit))
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("database error: failed to update api key: %w", err)
+	}
+	return key, nil
+}
+
+func (db *DB) RevokeAPIKeyTx(ctx context.Context, tx pgx.Tx, id int64) error {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+
+	ct, err := tx.Exec(ctx, query, id)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return err
+		}
+		return fmt.Errorf("database error: failed to revoke api key: %w", err)
+	}
+
+	if ct.RowsAffected() == 0 {
+		return models.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (db *DB) GetAPIKeyByID(ctx context.Context, id int64) (*models.APIKey, error) {
+	query := `
+		SELECT id, name, prefix, scopes, user_ids, rate_limit, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE id = $1
+	`
+	key, err := scanAPIKey(db.pool.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("database error: failed to get api key: %w", err)
+	}
+	return key, nil
+}
+
+func (db *DB) GetAPIKeyByHash(ctx context.Context, keyHash []byte) (*models.APIKey, error) {
+	query := `
+		SELECT id, name, prefix, scopes, user_ids, rate_limit, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE key_hash = $1
+	`
+	key, err := scanAPIKey(db.pool.QueryRow(ctx, query, keyHash))
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("database error: failed to get api key: %w", err)
+	}
+	return key, nil
+}
+
+func (db *DB) GetListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	query := `
+		SELECT id, name, prefix, scopes, user_ids, rate_limit, created_at, last_used_at, revoked_at
+		FROM api_keys
+		ORDER BY id
+	`
+	rows, err := db.pool.Query(ctx, query)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("database error: failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("database error: failed to scan api key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: failed to iterate over api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (db *DB) TouchAPIKey(ctx context.Context, id int64) error {
+	query := `
+		UPDATE api_keys
+		SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`
+	if _, err := db.pool.Exec(ctx, query, id); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return err
+		}
+		return fmt.Errorf("database error: failed to touch api key: %w", err)
+	}
+	return nil
+}
+
+func scanAPIKey(row pgx.Row) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Scopes, &key.UserIDs, &key.RateLimit, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
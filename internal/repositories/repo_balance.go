@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
 	"github.com/jackc/pgerrcode"
@@ -12,25 +13,41 @@ import (
 )
 
 func (db *DB) GetBalanceByUserID(ctx context.Context, userID int64) (*models.Balance, error) {
+	return db.getBalance(ctx, userID, nil)
+}
+
+func (db *DB) GetBalanceByUserIDAt(ctx context.Context, userID int64, at time.Time) (*models.Balance, error) {
+	return db.getBalance(ctx, userID, &at)
+}
+
+func (db *DB) getBalance(ctx context.Context, userID int64, at *time.Time) (*models.Balance, error) {
 	query := `
 		WITH
   			o AS (
     			SELECT COALESCE(SUM(accrual), 0) AS total_accrual
     			FROM orders
     			WHERE user_id = $1 AND status = 'PROCESSED'
+    				AND ($2::timestamptz IS NULL OR COALESCE(processed_at, uploaded_at) < $2)
   			),
   			w AS (
     			SELECT COALESCE(SUM("sum"), 0) AS total_withdrawn
     			FROM withdrawals
     			WHERE user_id = $1
+    				AND ($2::timestamptz IS NULL OR processed_at < $2)
+  			),
+  			a AS (
+    			SELECT COALESCE(SUM(amount), 0) AS total_adjusted
+    			FROM adjustments
+    			WHERE user_id = $1
+    				AND ($2::timestamptz IS NULL OR created_at < $2)
   			)
 		SELECT
-  			(o.total_accrual - w.total_withdrawn) AS current,
+  			(o.total_accrual + a.total_adjusted - w.total_withdrawn) AS current,
   			w.total_withdrawn AS withdrawn
-		FROM o, w;
+		FROM o, w, a;
 `
 	var balance models.Balance
-	err := db.pool.QueryRow(ctx, query, userID).Scan(&balance.Current, &balance.Withdrawn)
+	err := db.pool.QueryRow(ctx, query, userID, at).Scan(&balance.Current, &balance.Withdrawn)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
 			return nil, err
@@ -45,6 +62,40 @@ func (db *DB) CreateWithdrawalTx(ctx context.Context, tx pgx.Tx, userID int64, w
 		return fmt.Errorf("database error: failed to acquire advisory lock: %w", err)
 	}
 
+	available, err := availableBalanceTx(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	if available < wd.Sum {
+		return models.ErrPaymentRequired
+	}
+
+	qInsert := `
+		INSERT INTO withdrawals (user_id, order_number, "sum")
+		VALUES ($1, $2, $3)
+	`
+	if _, err = tx.Exec(ctx, qInsert, userID, wd.Order, wd.Sum); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return err
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return models.ErrWithdrawalOrderExists
+		}
+		return fmt.Errorf("database error: failed to insert withdrawal: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB) GetAvailableBalanceTx(ctx context.Context, tx pgx.Tx, userID int64) (float64, error) {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, userID); err != nil {
+		return 0, fmt.Errorf("database error: failed to acquire advisory lock: %w", err)
+	}
+	return availableBalanceTx(ctx, tx, userID)
+}
+
+func availableBalanceTx(ctx context.Context, tx pgx.Tx, userID int64) (float64, error) {
 	qAccrual := `
 		SELECT COALESCE(SUM(accrual), 0)
 		FROM orders
@@ -55,43 +106,34 @@ func (db *DB) CreateWithdrawalTx(ctx context.Context, tx pgx.Tx, userID int64, w
 		FROM withdrawals
 		WHERE user_id = $1
 	`
+	qAdjusted := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM adjustments
+		WHERE user_id = $1
+	`
 
-	var totalAccrual, totalWithdrawn float64
+	var totalAccrual, totalWithdrawn, totalAdjusted float64
 
 	if err := tx.QueryRow(ctx, qAccrual, userID).Scan(&totalAccrual); err != nil {
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-			return err
+			return 0, err
 		}
-		return fmt.Errorf("database error: failed to get accrual sum: %w", err)
+		return 0, fmt.Errorf("database error: failed to get accrual sum: %w", err)
 	}
 	if err := tx.QueryRow(ctx, qWithdrawn, userID).Scan(&totalWithdrawn); err != nil {
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-			return err
+			return 0, err
 		}
-		return fmt.Errorf("database error: failed to get withdrawn sum: %w", err)
+		return 0, fmt.Errorf("database error: failed to get withdrawn sum: %w", err)
 	}
-
-	available := totalAccrual - totalWithdrawn
-	if available < wd.Sum {
-		return models.ErrPaymentRequired
-	}
-
-	qInsert := `
-		INSERT INTO withdrawals (user_id, order_number, "sum")
-		VALUES ($1, $2, $3)
-	`
-	if _, err := tx.Exec(ctx, qInsert, userID, wd.Order, wd.Sum); err != nil {
-		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-			return err
-		}
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-			return models.ErrWithdrawalOrderExists
+	if err := tx.QueryRow(ctx, qAdjusted, userID).Scan(&totalAdjusted); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return 0, err
 		}
-		return fmt.Errorf("database error: failed to insert withdrawal: %w", err)
+		return 0, fmt.Errorf("database error: failed to get adjusted sum: %w", err)
 	}
 
-	return nil
+	return totalAccrual + totalAdjusted - totalWithdrawn, nil
 }
 
 func (db *DB) GetListWithdrawals(ctx context.Context, userID int64) ([]models.Withdrawal, error) {
@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+)
+
+func (db *DB) EnqueueJob(ctx context.Context, job *models.Job) (bool, error) {
+	query := `
+		INSERT INTO jobs (type, key, owner, payload, priority, max_attempts, run_at)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5, $6, $7)
+		ON CONFLICT (type, key) WHERE key IS NOT NULL AND status IN ('PENDING', 'RUNNING') DO NOTHING
+	`
+	payload := job.Payload
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+
+	ct, err := db.pool.Exec(ctx, query, job.Type, job.Key, job.Owner, payload, job.Priority, job.MaxAttempts, job.RunAt)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return false, err
+		}
+		return false, fmt.Errorf("database error: failed to enqueue job: %w", err)
+	}
+	return ct.RowsAffected() > 0, nil
+}
+
+func (db *DB) ClaimJobs(ctx context.Context, owner string, types []string, limit int, ttl time.Duration) ([]models.Job, error) {
+	query := `
+		WITH c AS (
+			SELECT id
+			FROM jobs
+			WHERE type = ANY($2)
+				AND (owner IS NULL OR owner = $1)
+				AND (status = 'PENDING' AND run_at <= NOW() OR status = 'RUNNING' AND locked_until < NOW())
+			ORDER BY priority DESC, run_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE jobs j
+		SET status = 'RUNNING',
+			attempts = j.attempts + 1,
+			locked_by = $1,
+			locked_until = NOW() + make_interval(secs => $4),
+			updated_at = NOW()
+		FROM c
+		WHERE j.id = c.id
+		RETURNING j.id, j.type, COALESCE(j.key, ''), COALESCE(j.owner, ''), j.payload, j.priority, j.status, j.attempts, j.max_attempts,
+			COALESCE(j.last_error, ''), j.run_at, j.created_at
+`
+	rows, err := db.pool.Query(ctx, query, owner, types, limit, ttl.Seconds())
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("database error: failed to claim jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []models.Job
+	for rows.Next() {
+		var job models.Job
+		if err = rows.Scan(&job.ID, &job.Type, &job.Key, &job.Owner, &job.Payload, &job.Priority, &job.Status, &job.Attempts,
+			&job.MaxAttempts, &job.LastError, &job.RunAt, &job.CreatedAt); err != nil {
+			return nil, fmt.Errorf("database error: failed to scan job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: failed to iterate over jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+func (db *DB) ExtendJobLease(ctx context.Context, owner string, id int64, ttl time.Duration) error {
+	query := `
+		UPDATE jobs
+		SET locked_until = NOW() + make_interval(secs => $3), updated_at = NOW()
+		WHERE id = $2 AND locked_by = $1 AND status = 'RUNNING'
+	`
+	return db.execJob(ctx, "extend job lease", query, owner, id, ttl.Seconds())
+}
+
+func (db *DB) CompleteJob(ctx context.Context, owner string, id int64) error {
+	query := `
+		DELETE FROM jobs
+		WHERE id = $2 AND locked_by = $1
+	`
+	return db.execJob(ctx, "complete job", query, owner, id)
+}
+
+func (db *DB) RetryJob(ctx context.Context, owner string, id int64, runAt time.Time, lastErr string) error {
+	query := `
+		UPDATE jobs
+		SET status = 'PENDING', run_at = $3, last_error = $4, locked_by = NULL, locked_until = NULL, updated_at = NOW()
+		WHERE id = $2 AND locked_by = $1
+	`
+	return db.execJob(ctx, "retry job", query, owner, id, runAt, lastErr)
+}
+
+func (db *DB) BuryJob(ctx context.Context, owner string, id int64, lastErr string) error {
+	query := `
+		UPDATE jobs
+		SET status = 'DEAD', last_error = $3, locked_by = NULL, locked_until = NULL, updated_at = NOW()
+		WHERE id = $2 AND locked_by = $1
+	`
+	return db.execJob(ctx, "bury job", query, owner, id, lastErr)
+}
+
+func (db *DB) ReleaseJob(ctx context.Context, owner string, id int64) error {
+	query := `
+		UPDATE jobs
+		SET status = 'PENDING', attempts = GREATEST(attempts - 1, 0), locked_by = NULL, locked_until = NULL, updated_at = NOW()
+		WHERE id = $2 AND locked_by = $1
+	`
+	return db.execJob(ctx, "release job", query, owner, id)
+}
+
+func (db *DB) execJob(ctx context.Context, op, query string, args ...any) error {
+	ct, err := db.pool.Exec(ctx, query, args...)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return err
+		}
+		return fmt.Errorf("database error: failed to %s: %w", op, err)
+	}
+
+	if ct.RowsAffected() == 0 {
+		return models.ErrJobLeaseLost
+	}
+	return nil
+}
+
+func (db *DB) PurgeDeadJobs(ctx context.Context, before time.Time) (int64, error) {
+	ct, err := db.pool.Exec(ctx, `DELETE FROM jobs WHERE status = 'DEAD' AND updated_at < $1`, before)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("database error: failed to purge dead jobs: %w", err)
+	}
+	return ct.RowsAffected(), nil
+}
+
+func (db *DB) PurgeOrphanedJobs(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM jobs
+		WHERE owner IS NOT NULL
+			AND (status = 'PENDING' AND run_at < $1 OR status = 'RUNNING' AND locked_until < $1)
+	`
+	ct, err := db.pool.Exec(ctx, query, before)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("database error: failed to purge orphaned jobs: %w", err)
+	}
+	return ct.RowsAffected(), nil
+}
@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+)
+
+func TestExtendJobLease(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	jobType := "test-" + testSuffix()
+	t.Cleanup(func() {
+		if _, err := db.pool.Exec(context.Background(), `DELETE FROM jobs WHERE type = $1`, jobType); err != nil {
+			t.Errorf("delete jobs: %v", err)
+		}
+	})
+	if _, err := db.EnqueueJob(ctx, &models.Job{Type: jobType, MaxAttempts: 1, RunAt: time.Now()}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	jobs, err := db.ClaimJobs(ctx, "owner-a", []string{jobType}, 1, 200*time.Millisecond)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("claim: %v, %d jobs", err, len(jobs))
+	}
+	id := jobs[0].ID
+
+	for range 3 {
+		time.Sleep(100 * time.Millisecond)
+		if err = db.ExtendJobLease(ctx, "owner-a", id, 200*time.Millisecond); err != nil {
+			t.Fatalf("extend: %v", err)
+		}
+	}
+	if stolen, err := db.ClaimJobs(ctx, "owner-b", []string{jobType}, 1, time.Minute); err != nil || len(stolen) != 0 {
+		t.Fatalf("owner-b claimed an extended lease: %v, %d jobs", err, len(stolen))
+	}
+
+	if err = db.ExtendJobLease(ctx, "owner-b", id, time.Minute); !errors.Is(err, models.ErrJobLeaseLost) {
+		t.Fatalf("owner-b extend: got %v, want ErrJobLeaseLost", err)
+	}
+	if err = db.CompleteJob(ctx, "owner-a", id); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if err = db.ExtendJobLease(ctx, "owner-a", id, time.Minute); !errors.Is(err, models.ErrJobLeaseLost) {
+		t.Fatalf("extend after complete: got %v, want ErrJobLeaseLost", err)
+	}
+}
+
+func TestClaimJobsSkipsJobsOwnedByOthers(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	jobType := "test-" + testSuffix()
+	t.Cleanup(func() {
+		if _, err := db.pool.Exec(context.Background(), `DELETE FROM jobs WHERE type = $1`, jobType); err != nil {
+			t.Errorf("delete jobs: %v", err)
+		}
+	})
+	for _, job := range []models.Job{
+		{Type: jobType, Key: "owner-a", Owner: "owner-a", MaxAttempts: 1, RunAt: time.Now()},
+		{Type: jobType, Key: "shared", MaxAttempts: 1, RunAt: time.Now()},
+		{Type: jobType, Key: "owner-gone", Owner: "owner-gone", MaxAttempts: 1, RunAt: time.Now().Add(-2 * time.Hour)},
+	} {
+		if _, err := db.EnqueueJob(ctx, &job); err != nil {
+			t.Fatalf("enqueue %s: %v", job.Key, err)
+		}
+	}
+
+	jobs, err := db.ClaimJobs(ctx, "owner-b", []string{jobType}, 10, time.Minute)
+	if err != nil || len(jobs) != 1 || jobs[0].Key != "shared" {
+		t.Fatalf("owner-b claim: %v, %+v", err, jobs)
+	}
+
+	jobs, err = db.ClaimJobs(ctx, "owner-a", []string{jobType}, 10, time.Minute)
+	if err != nil || len(jobs) != 1 || jobs[0].Key != "owner-a" || jobs[0].Owner != "owner-a" {
+		t.Fatalf("owner-a claim: %v, %+v", err, jobs)
+	}
+
+	if _, err = db.PurgeOrphanedJobs(ctx, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("purge orphaned: %v", err)
+	}
+	var keys []string
+	rows, err := db.pool.Query(ctx, `SELECT key FROM jobs WHERE type = $1 ORDER BY key`, jobType)
+	if err != nil {
+		t.Fatalf("select jobs: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) != 2 || keys[0] != "owner-a" || keys[1] != "shared" {
+		t.Fatalf("jobs after purge: %v, want the orphaned job removed", keys)
+	}
+}
@@ -11,12 +11,12 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
-func (db *DB) InsertOrder(ctx context.Context, userID int64, num string) error {
+func (db *DB) InsertOrder(ctx context.Context, userID int64, num, provider string) error {
 	query := `
-		INSERT INTO orders (user_id, number)
-		VALUES ($1, $2)
+		INSERT INTO orders (user_id, number, provider)
+		VALUES ($1, $2, $3)
 	`
-	_, err := db.pool.Exec(ctx, query, userID, num)
+	_, err := db.pool.Exec(ctx, query, userID, num, provider)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
 			return err
@@ -81,3 +81,31 @@ func (db *DB) GetOrdersByUserID(ctx context.Context, userID int64) ([]models.Ord
 
 	return orders, nil
 }
+
+func (db *DB) CountOrdersByStatus(ctx context.Context, statuses []string) (map[string]int64, error) {
+	rows, err := db.pool.Query(ctx, `SELECT status, COUNT(*) FROM orders WHERE status = ANY($1) GROUP BY status`, statuses)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("database error: failed to count orders: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64, len(statuses))
+	for rows.Next() {
+		var (
+			status string
+			n      int64
+		)
+		if err = rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("database error: failed to scan order count: %w", err)
+		}
+		counts[status] = n
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: failed to iterate over order counts: %w", err)
+	}
+	return counts, nil
+}
@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DB) GetOrdersForReconciliation(ctx context.Context, since time.Time, providers []string, limit int) ([]models.Order, error) {
+	query := `
+		SELECT o.id, o.user_id, o.number, o.status, o.provider, o.uploaded_at,
+			o.accrual + COALESCE((
+				SELECT SUM(d.reported_accrual - d.expected_accrual)
+				FROM accrual_discrepancies d
+				WHERE d.order_id = o.id AND d.status = 'CORRECTED'
+			), 0) AS accrual
+		FROM orders o
+		WHERE o.status = 'PROCESSED' AND o.processed_at >= $1 AND o.provider = ANY($2)
+		ORDER BY o.reconciled_at NULLS FIRST, o.processed_at DESC
+		LIMIT $3
+`
+	rows, err := db.pool.Query(ctx, query, since, providers, limit)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("database error: failed to get orders for reconciliation: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	for rows.Next() {
+		var order models.Order
+		if err = rows.Scan(&order.ID, &order.UserID, &order.Number, &order.Status, &order.Provider, &order.UploadedAt, &order.Accrual); err != nil {
+			return nil, fmt.Errorf("database error: failed to scan order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: failed to iterate over orders: %w", err)
+	}
+	return orders, nil
+}
+
+func (db *DB) MarkOrderReconciled(ctx context.Context, id int64) error {
+	if _, err := db.pool.Exec(ctx, `UPDATE orders SET reconciled_at = NOW() WHERE id = $1`, id); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return err
+		}
+		return fmt.Errorf("database error: failed to mark order reconciled: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) UpsertDiscrepancyTx(ctx context.Context, tx pgx.Tx, d *models.Discrepancy) (int64, error) {
+	query := `
+		INSERT INTO accrual_discrepancies (order_id, expected_accrual, reported_status, reported_accrual)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (
+			SELECT 1
+			FROM accrual_discrepancies
+			WHERE order_id = $1 AND status = 'DISMISSED' AND reported_status = $3 AND reported_accrual = $4
+		)
+		ON CONFLICT (order_id) WHERE status = 'OPEN' DO UPDATE
+		SET expected_accrual = EXCLUDED.expected_accrual,
+			reported_status = EXCLUDED.reported_status,
+			reported_accrual = EXCLUDED.reported_accrual,
+			detected_at = NOW()
+		RETURNING id
+	`
+	var id int64
+	err := tx.QueryRow(ctx, query, d.OrderID, d.ExpectedAccrual, d.ReportedStatus, d.ReportedAccrual).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("database error: failed to upsert discrepancy: %w", err)
+	}
+	return id, nil
+}
+
+func (db *DB) GetDiscrepancies(ctx context.Context, status string, limit int) ([]models.Discrepancy, error) {
+	query := `
+		SELECT d.id, d.order_id, o.user_id, o.number, o.provider, d.expected_accrual, d.reported_status,
+			d.reported_accrual, d.status, d.adjustment_id, d.detected_at, d.resolved_at
+		FROM accrual_discrepancies d
+		JOIN orders o ON o.id = d.order_id
+		WHERE ($1 = '' OR d.status = $1)
+		ORDER BY d.detected_at DESC
+		LIMIT $2
+`
+	rows, err := db.pool.Query(ctx, query, status, limit)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("database error: failed to list discrepancies: %w", err)
+	}
+	defer rows.Close()
+
+	var discrepancies []models.Discrepancy
+	for rows.Next() {
+		d, err := scanDiscrepancy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("database error: failed to scan discrepancy: %w", err)
+		}
+		discrepancies = append(discrepancies, *d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: failed to iterate over discrepancies: %w", err)
+	}
+	return discrepancies, nil
+}
+
+func (db *DB) GetDiscrepancyForUpdateTx(ctx context.Context, tx pgx.Tx, id int64) (*models.Discrepancy, error) {
+	query := `
+		SELECT d.id, d.order_id, o.user_id, o.number, o.provider, d.expected_accrual, d.reported_status,
+			d.reported_accrual, d.status, d.adjustment_id, d.detected_at, d.resolved_at
+		FROM accrual_discrepancies d
+		JOIN orders o ON o.id = d.order_id
+		WHERE d.id = $1
+		FOR UPDATE OF d
+	`
+	d, err := scanDiscrepancy(tx.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrDiscrepancyNotFound
+		}
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("database error: failed to get discrepancy: %w", err)
+	}
+	return d, nil
+}
+
+func (db *DB) ResolveDiscrepancyTx(ctx context.Context, tx pgx.Tx, id int64, status string, adjustmentID, actorID int64) error {
+	query := `
+		UPDATE accrual_discrepancies
+		SET status = $2, adjustment_id = NULLIF($3, 0), resolved_by = NULLIF($4, 0), resolved_at = NOW()
+		WHERE id = $1 AND status = 'OPEN'
+	`
+	ct, err := tx.Exec(ctx, query, id, status, adjustmentID, actorID)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return err
+		}
+		return fmt.Errorf("database error: failed to resolve discrepancy: %w", err)
+	}
+
+	if ct.RowsAffected() == 0 {
+		return models.ErrDiscrepancyResolved
+	}
+	return nil
+}
+
+func scanDiscrepancy(row pgx.Row) (*models.Discrepancy, error) {
+	var d models.Discrepancy
+	if err := row.Scan(&d.ID, &d.OrderID, &d.UserID, &d.Number, &d.Provider, &d.ExpectedAccrual, &d.ReportedStatus,
+		&d.ReportedAccrual, &d.Status, &d.AdjustmentID, &d.DetectedAt, &d.ResolvedAt); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
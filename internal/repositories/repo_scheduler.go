@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+)
+
+func (db *DB) RegisterScheduledTask(ctx context.Context, name, spec string, nextRunAt time.Time) error {
+	query := `
+		INSERT INTO scheduled_tasks (name, spec, next_run_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE
+		SET spec = EXCLUDED.spec, next_run_at = EXCLUDED.next_run_at, updated_at = NOW()
+		WHERE scheduled_tasks.spec <> EXCLUDED.spec
+	`
+	if _, err := db.pool.Exec(ctx, query, name, spec, nextRunAt); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return err
+		}
+		return fmt.Errorf("database error: failed to register scheduled task: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetScheduledTasks(ctx context.Context) ([]models.ScheduledTask, error) {
+	query := `
+		SELECT name, spec, next_run_at, last_run_at, COALESCE(last_status, ''), COALESCE(last_error, ''),
+			COALESCE(last_duration, 0)
+		FROM scheduled_tasks
+		ORDER BY name
+	`
+	rows, err := db.pool.Query(ctx, query)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("database error: failed to get scheduled tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []models.ScheduledTask
+	for rows.Next() {
+		var t models.ScheduledTask
+		if err = rows.Scan(&t.Name, &t.Spec, &t.NextRunAt, &t.LastRunAt, &t.LastStatus, &t.LastError, &t.LastDuration); err != nil {
+			return nil, fmt.Errorf("database error: failed to scan scheduled task: %w", err)
+		}
+		tasks = append(tasks, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: failed to iterate over scheduled tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+func (db *DB) AdvanceScheduledTask(ctx context.Context, name string, prev, next time.Time) (bool, error) {
+	query := `
+		UPDATE scheduled_tasks
+		SET next_run_at = $3, updated_at = NOW()
+		WHERE name = $1 AND next_run_at = $2
+	`
+	ct, err := db.pool.Exec(ctx, query, name, prev, next)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return false, err
+		}
+		return false, fmt.Errorf("database error: failed to advance scheduled task: %w", err)
+	}
+	return ct.RowsAffected() > 0, nil
+}
+
+func (db *DB) RecordScheduledTaskRun(ctx context.Context, name string, startedAt time.Time, duration time.Duration, lastErr string) error {
+	query := `
+		UPDATE scheduled_tasks
+		SET last_run_at = $2,
+			last_status = CASE WHEN $4 = '' THEN 'SUCCEEDED' ELSE 'FAILED' END,
+			last_error = NULLIF($4, ''),
+			last_duration = $3,
+			updated_at = NOW()
+		WHERE name = $1
+	`
+	if _, err := db.pool.Exec(ctx, query, name, startedAt, duration.Seconds(), lastErr); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return err
+		}
+		return fmt.Errorf("database error: failed to record scheduled task run: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+)
+
+const transactionsCTE = `
+	t AS (
+		SELECT 'ACCRUAL' AS type, id, number AS order_number, '' AS description,
+			accrual AS amount, COALESCE(processed_at, uploaded_at) AS created_at
+		FROM orders
+		WHERE user_id = $1 AND status = 'PROCESSED'
+		UNION ALL
+		SELECT 'WITHDRAWAL', id, order_number, '', -"sum", processed_at
+		FROM withdrawals
+		WHERE user_id = $1
+		UNION ALL
+		SELECT 'ADJUSTMENT', id, '', reason, amount, created_at
+		FROM adjustments
+		WHERE user_id = $1
+	)`
+
+func (db *DB) GetTransactionsByUserID(ctx context.Context, userID int64, limit, offset int) ([]models.Transaction, error) {
+	query := `
+		WITH ` + transactionsCTE + `,
+			r AS (
+				SELECT type, id, order_number, description, amount, created_at,
+					SUM(amount) OVER (ORDER BY created_at, type, id) AS balance
+				FROM t
+			)
+		SELECT type, order_number, description, amount, balance, created_at
+		FROM r
+		ORDER BY created_at DESC, type DESC, id DESC
+		LIMIT $2 OFFSET $3
+`
+	rows, err := db.pool.Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("database error: failed to get transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		var t models.Transaction
+		if err = rows.Scan(&t.Type, &t.Order, &t.Description, &t.Amount, &t.Balance, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("database error: failed to scan transaction: %w", err)
+		}
+		transactions = append(transactions, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: failed to iterate over transactions: %w", err)
+	}
+	return transactions, nil
+}
+
+func (db *DB) StreamTransactionsByPeriod(ctx context.Context, userID int64, from, to time.Time, fn func(*models.Transaction) error) error {
+	query := `
+		WITH ` + transactionsCTE + `
+		SELECT type, order_number, description, amount, created_at
+		FROM t
+		WHERE created_at >= $2 AND created_at < $3
+		ORDER BY created_at, type, id
+`
+	rows, err := db.pool.Query(ctx, query, userID, from, to)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return err
+		}
+		return fmt.Errorf("database error: failed to get transactions by period: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.Transaction
+		if err = rows.Scan(&t.Type, &t.Order, &t.Description, &t.Amount, &t.CreatedAt); err != nil {
+			return fmt.Errorf("database error: failed to scan transaction: %w", err)
+		}
+		if err = fn(&t); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("database error: failed to iterate over transactions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+)
+
+func TestStatementBalancesAddUp(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	userID := createTestUser(t, db)
+
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	prefix := testSuffix()
+
+	orders := []struct {
+		accrual     float64
+		processedAt time.Time
+	}{
+		{500, from.Add(-time.Hour)},
+		{300, from},
+		{0.1, from.Add(72 * time.Hour)},
+		{1000, to},
+	}
+	for i, o := range orders {
+		if _, err := db.pool.Exec(ctx, `
+			INSERT INTO orders (user_id, number, status, accrual, uploaded_at, processed_at)
+			VALUES ($1, $2, 'PROCESSED', $3, $4, $4)`,
+			userID, prefix+string(rune('a'+i)), o.accrual, o.processedAt); err != nil {
+			t.Fatalf("insert order: %v", err)
+		}
+	}
+	if _, err := db.pool.Exec(ctx, `
+		INSERT INTO withdrawals (user_id, order_number, sum, processed_at)
+		VALUES ($1, $2, 120.5, $3), ($1, $4, 50.25, $5)`,
+		userID, prefix+"w1", from.Add(-time.Minute), prefix+"w2", from.Add(48*time.Hour)); err != nil {
+		t.Fatalf("insert withdrawals: %v", err)
+	}
+	if _, err := db.pool.Exec(ctx, `
+		INSERT INTO adjustments (user_id, amount, reason, created_at)
+		VALUES ($1, 0.2, 'test', $2), ($1, -7, 'test', $3)`,
+		userID, to.Add(-time.Microsecond), to); err != nil {
+		t.Fatalf("insert adjustments: %v", err)
+	}
+
+	opening, err := db.GetBalanceByUserIDAt(ctx, userID, from)
+	if err != nil {
+		t.Fatalf("opening balance: %v", err)
+	}
+	closing, err := db.GetBalanceByUserIDAt(ctx, userID, to)
+	if err != nil {
+		t.Fatalf("closing balance: %v", err)
+	}
+
+	sum, rows := opening.Current, 0
+	err = db.StreamTransactionsByPeriod(ctx, userID, from, to, func(tx *models.Transaction) error {
+		sum += tx.Amount
+		rows++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+
+	if opening.Current != 379.5 || rows != 4 {
+		t.Fatalf("opening %v with %d rows, want 379.5 with 4 rows", opening.Current, rows)
+	}
+	if diff := sum - closing.Current; diff > 1e-9 || diff < -1e-9 {
+		t.Fatalf("opening %v plus rows gives %v, closing is %v", opening.Current, sum, closing.Current)
+	}
+}
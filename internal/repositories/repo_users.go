@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
 	"github.com/jackc/pgx/v5"
@@ -33,13 +34,13 @@ func (db *DB) CreateUser(ctx context.Context, login string, passHash []byte) (in
 
 func (db *DB) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
 	query := `
-		SELECT id, login, password_hash, created_at
+		SELECT id, login, password_hash, role, created_at
 		FROM users
 		WHERE login = $1
 	`
 
 	var u models.User
-	if err := db.pool.QueryRow(ctx, query, login).Scan(&u.ID, &u.Login, &u.PasswordHash, &u.CreatedAt); err != nil {
+	if err := db.pool.QueryRow(ctx, query, login).Scan(&u.ID, &u.Login, &u.PasswordHash, &u.Role, &u.CreatedAt); err != nil {
 		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
 			return nil, err
 		}
@@ -51,21 +52,55 @@ func (db *DB) GetUserByLogin(ctx context.Context, login string) (*models.User, e
 	return &u, nil
 }
 
-//func (db *DB) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
-//	query := `
-//		SELECT id, login, password_hash, created_at
-//		FROM users
-//		WHERE id = $1
-//	`
-//	var u models.User
-//	if err := db.pool.QueryRow(ctx, query, id).Scan(&u.ID, &u.Login, &u.PasswordHash, &u.CreatedAt); err != nil {
-//		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-//			return nil, err
-//		}
-//		if errors.Is(err, pgx.ErrNoRows) {
-//			return nil, models.ErrUserNotFound
-//		}
-//		return nil, fmt.Errorf("database error: failed to get user: %w", err)
-//	}
-//	return &u, nil
-//}
+func (db *DB) GetUserByID(ctx context.Context, id int64) (*models.UserInfo, error) {
+	query := `
+		SELECT id, login, role, created_at
+		FROM users
+		WHERE id = $1
+	`
+	var u models.UserInfo
+	if err := db.pool.QueryRow(ctx, query, id).Scan(&u.ID, &u.Login, &u.Role, &u.CreatedAt); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("database error: failed to get user: %w", err)
+	}
+	return &u, nil
+}
+
+func (db *DB) SearchUsersByLogin(ctx context.Context, login string, limit int) ([]models.UserInfo, error) {
+	query := `
+		SELECT id, login, role, created_at
+		FROM users
+		WHERE login ILIKE '%' || $1 || '%'
+		ORDER BY login
+		LIMIT $2
+	`
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(login)
+
+	rows, err := db.pool.Query(ctx, query, pattern, limit)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("database error: failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []models.UserInfo
+	for rows.Next() {
+		var u models.UserInfo
+		if err = rows.Scan(&u.ID, &u.Login, &u.Role, &u.CreatedAt); err != nil {
+			return nil, fmt.Errorf("database error: failed to scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: failed to iterate over users: %w", err)
+	}
+	return users, nil
+}
@@ -4,133 +4,498 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/metrics"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/tracing"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
-	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 )
 
+const releaseTimeout = 5 * time.Second
+
+type pollOutcome int
+
+const (
+	pollUpdated pollOutcome = iota
+	pollRescheduled
+	pollAborted
+	pollThrottled
+)
+
 type AccrualClient interface {
-	GetOrderAccrual(ctx context.Context, number string) (*models.AccrualResp, time.Duration, error)
+	Ready() bool
+	BatchSize() int
+	GetOrderAccrual(ctx context.Context, number string) (*models.AccrualResp, error)
+	GetOrdersAccrual(ctx context.Context, numbers []string) (map[string]*models.AccrualResp, error)
+}
+
+type RequestLimiter interface {
+	Acquire(ctx context.Context) (func(), error)
+	Wait(ctx context.Context) error
+	OnSuccess()
+	OnThrottle(retryAfter time.Duration, limitPerMinute int)
+	Rate() float64
 }
 
 type AccrualRepository interface {
-	SelectOrdersForAccrualPollingTx(ctx context.Context, tx pgx.Tx, limit int) ([]models.Order, error)
-	UpdateOrderStatusAndAccrual(ctx context.Context, accrualResp *models.AccrualResp) error
-	MarkOrdersProcessingTx(ctx context.Context, tx pgx.Tx, ids []int64) error
-	BeginTx(ctx context.Context) (pgx.Tx, error)
+	ClaimOrdersForAccrualPolling(ctx context.Context, owner string, providers []string, limit int, ttl time.Duration) ([]models.Order, error)
+	ExtendOrderLeases(ctx context.Context, owner string, ids []int64, ttl time.Duration) error
+	ReleaseOrderLeases(ctx context.Context, owner string, ids []int64) error
+	ApplyOrderAccrual(ctx context.Context, owner string, accrualResp *models.AccrualResp) (bool, error)
+	ScheduleOrderPoll(ctx context.Context, owner string, id int64, attempts int, nextPollAt time.Time) error
+	ParkOrder(ctx context.Context, owner string, id int64, attempts int) error
+	ReassignOrderProviders(ctx context.Context, known []string, fallback string) (int64, error)
+}
+
+type PollPolicy struct {
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	MaxAttempts int
+}
+
+func (p PollPolicy) Backoff(attempts int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempts && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+type AccrualProvider struct {
+	Name    string
+	Client  AccrualClient
+	Limiter RequestLimiter
+}
+
+type AccrualOptions struct {
+	BatchSize int
+	Owner     string
+	LeaseTTL  time.Duration
+	Poll      PollPolicy
 }
 
 type AccrualService struct {
-	client    AccrualClient
+	providers map[string]*AccrualProvider
 	repo      AccrualRepository
 	logger    *zap.Logger
-	batchSize int
+	opts      AccrualOptions
+	lastPoll  atomic.Int64
 }
 
-func NewAccrualService(client AccrualClient, repo AccrualRepository, logger *zap.Logger, batchSize int) *AccrualService {
-	if batchSize <= 0 {
-		batchSize = 100
+func NewAccrualService(providers []AccrualProvider, repo AccrualRepository, logger *zap.Logger, opts AccrualOptions) *AccrualService {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
 	}
-	return &AccrualService{
-		client:    client,
+	if opts.LeaseTTL <= 0 {
+		opts.LeaseTTL = 30 * time.Second
+	}
+	byName := make(map[string]*AccrualProvider, len(providers))
+	for i := range providers {
+		byName[providers[i].Name] = &providers[i]
+	}
+	as := &AccrualService{
+		providers: byName,
 		repo:      repo,
-		logger:    logger.With(zap.String("service", "accrual")),
-		batchSize: batchSize,
+		logger:    logger.With(zap.String("service", "accrual"), zap.String("owner", opts.Owner)),
+		opts:      opts,
 	}
+	as.lastPoll.Store(time.Now().UnixNano())
+	return as
 }
 
-func (as *AccrualService) PollAndUpdate(ctx context.Context) (int, time.Duration, error) {
-	tx, err := as.repo.BeginTx(ctx)
+func (as *AccrualService) LastPoll() time.Time {
+	return time.Unix(0, as.lastPoll.Load())
+}
+
+func (as *AccrualService) Available() bool {
+	for _, p := range as.providers {
+		if p.Client.Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+func (as *AccrualService) Rates() map[string]float64 {
+	rates := make(map[string]float64, len(as.providers))
+	for name, p := range as.providers {
+		rates[name] = p.Limiter.Rate()
+	}
+	return rates
+}
+
+func (as *AccrualService) AdoptOrphanedOrders(ctx context.Context, fallback string) error {
+	if _, ok := as.providers[fallback]; !ok {
+		return fmt.Errorf("accrual provider %q is not configured", fallback)
+	}
+	known := make([]string, 0, len(as.providers))
+	for name := range as.providers {
+		known = append(known, name)
+	}
+
+	n, err := as.repo.ReassignOrderProviders(ctx, known, fallback)
 	if err != nil {
-		return 0, 0, fmt.Errorf("error starting transaction: %w", err)
+		return err
+	}
+	if n > 0 {
+		logctx.FromContext(ctx, as.logger).Warn("orders of unknown accrual providers reassigned", zap.String("provider", fallback), zap.Int64("orders", n))
 	}
-	defer tx.Rollback(ctx)
+	return nil
+}
+
+func (as *AccrualService) PollAndUpdate(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "AccrualService.PollAndUpdate")
+	defer span.End()
+	as.lastPoll.Store(time.Now().UnixNano())
 
-	orders, err := as.repo.SelectOrdersForAccrualPollingTx(ctx, tx, as.batchSize)
+	var ready []string
+	for name, p := range as.providers {
+		if p.Client.Ready() {
+			ready = append(ready, name)
+		}
+	}
+	if len(ready) == 0 {
+		return 0, models.ErrAccrualUnavailable
+	}
+
+	orders, err := as.repo.ClaimOrdersForAccrualPolling(ctx, as.opts.Owner, ready, as.opts.BatchSize, as.opts.LeaseTTL)
 	if err != nil {
-		return 0, 0, fmt.Errorf("error selecting orders: %w", err)
+		return 0, fmt.Errorf("error claiming orders: %w", err)
 	}
 	if len(orders) == 0 {
-		return 0, 0, nil
+		return 0, nil
 	}
 
-	ids := make([]int64, 0, len(orders))
-	for _, order := range orders {
-		if order.Status == models.StatusNew {
-			ids = append(ids, order.ID)
+	lease := newLeaseSet(orders)
+	stopHeartbeat := as.startLeaseHeartbeat(ctx, lease)
+	defer func() {
+		stopHeartbeat()
+		as.releaseLeases(ctx, lease)
+	}()
+
+	pollCtxs := make(map[string]context.Context, len(ready))
+	cancels := make(map[string]context.CancelCauseFunc, len(ready))
+	for _, name := range ready {
+		pollCtx, cancel := context.WithCancelCause(ctx)
+		pollCtxs[name], cancels[name] = pollCtx, cancel
+		defer cancel(nil)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		processed int
+	)
+	for name, group := range groupByProvider(orders) {
+		p, pollCtx := as.providers[name], pollCtxs[name]
+		if p == nil || pollCtx == nil {
+			continue
+		}
+
+		for chunk := range slices.Chunk(group, max(1, p.Client.BatchSize())) {
+			wg.Add(1)
+			go func(chunk []models.Order) {
+				defer wg.Done()
+
+				release, err := p.Limiter.Acquire(pollCtx)
+				if err != nil {
+					return
+				}
+				defer release()
+
+				for i, outcome := range as.pollChunk(ctx, pollCtx, p, chunk) {
+					switch outcome {
+					case pollThrottled:
+						cancels[name](models.ErrAccrualOrderTooMany)
+						continue
+					case pollAborted:
+						continue
+					case pollUpdated:
+						mu.Lock()
+						processed++
+						mu.Unlock()
+					}
+					lease.done(chunk[i].ID)
+				}
+			}(chunk)
 		}
 	}
-	if len(ids) > 0 {
-		if err = as.repo.MarkOrdersProcessingTx(ctx, tx, ids); err != nil {
-			return 0, 0, fmt.Errorf("error processing orders: %w", err)
+	wg.Wait()
+
+	if err = ctx.Err(); err != nil {
+		return processed, err
+	}
+	return processed, nil
+}
+
+func (as *AccrualService) pollChunk(ctx, pollCtx context.Context, p *AccrualProvider, orders []models.Order) []pollOutcome {
+	if len(orders) == 1 {
+		accrualResp, err := p.Client.GetOrderAccrual(pollCtx, orders[0].Number)
+		if err != nil && !errors.Is(err, models.ErrAccrualOrderNotRegistered) {
+			return as.handleError(ctx, pollCtx, p, orders, err)
 		}
+		p.Limiter.OnSuccess()
+		return []pollOutcome{as.handleResult(ctx, &orders[0], accrualResp)}
 	}
-	if err = tx.Commit(ctx); err != nil {
-		return 0, 0, fmt.Errorf("error committing transaction: %w", err)
+
+	numbers := make([]string, len(orders))
+	for i := range orders {
+		numbers[i] = orders[i].Number
 	}
 
-	var processed int
-	for _, order := range orders {
-		select {
-		case <-ctx.Done():
-			return processed, 0, ctx.Err()
-		default:
+	results, err := p.Client.GetOrdersAccrual(pollCtx, numbers)
+	if errors.Is(err, models.ErrAccrualBatchUnsupported) {
+		logctx.FromContext(ctx, as.logger).Info("accrual batch lookup unsupported, falling back to single requests", zap.String("provider", p.Name))
+		return as.pollOrders(ctx, pollCtx, p, orders)
+	}
+	if err != nil {
+		return as.handleError(ctx, pollCtx, p, orders, err)
+	}
+	p.Limiter.OnSuccess()
+
+	outcomes := make([]pollOutcome, len(orders))
+	for i := range orders {
+		outcomes[i] = as.handleResult(ctx, &orders[i], results[orders[i].Number])
+	}
+	return outcomes
+}
+
+func (as *AccrualService) pollOrders(ctx, pollCtx context.Context, p *AccrualProvider, orders []models.Order) []pollOutcome {
+	outcomes := make([]pollOutcome, len(orders))
+	for i := range outcomes {
+		outcomes[i] = pollAborted
+	}
+	for i := range orders {
+		if err := p.Limiter.Wait(pollCtx); err != nil {
+			break
 		}
+		outcomes[i] = as.pollChunk(ctx, pollCtx, p, orders[i:i+1])[0]
+		if outcomes[i] == pollThrottled || outcomes[i] == pollAborted {
+			break
+		}
+	}
+	return outcomes
+}
 
-		accrualResp, retryAfter, clErr := as.client.GetOrderAccrual(ctx, order.Number)
-		if clErr != nil {
-			if errors.Is(clErr, models.ErrAccrualOrderNotRegistered) {
-				continue
-			}
-			if errors.Is(clErr, models.ErrAccrualOrderTooMany) {
-				return processed, retryAfter, nil
-			}
-			as.logger.Error("accrual client error", zap.String("order", order.Number), zap.Error(clErr))
-			continue
+func (as *AccrualService) handleError(ctx, pollCtx context.Context, p *AccrualProvider, orders []models.Order, err error) []pollOutcome {
+	outcome := pollRescheduled
+
+	var throttled *models.AccrualThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		if pollCtx.Err() == nil {
+			as.throttle(p, throttled)
+		}
+		outcome = pollThrottled
+	case pollCtx.Err() != nil, errors.Is(err, models.ErrAccrualUnavailable):
+		outcome = pollAborted
+	default:
+		logctx.FromContext(ctx, as.logger).Error("accrual client error", zap.String("provider", p.Name), zap.Int("orders", len(orders)), zap.String("order", orders[0].Number), zap.Error(err))
+		for i := range orders {
+			as.scheduleRetry(ctx, &orders[i])
 		}
+	}
 
-		switch accrualResp.Status {
-		case models.StatusRegistered, models.StatusProcessing:
-			upd := &models.AccrualResp{
-				Order:  order.Number,
-				Status: models.StatusProcessing,
-			}
-			if err = as.repo.UpdateOrderStatusAndAccrual(ctx, upd); err != nil {
-				as.logger.Error("failed to update order to PROCESSING", zap.String("order", order.Number), zap.Error(err))
-				continue
-			}
-			processed++
+	outcomes := make([]pollOutcome, len(orders))
+	for i := range outcomes {
+		outcomes[i] = outcome
+	}
+	return outcomes
+}
 
-		case models.StatusInvalid:
-			upd := &models.AccrualResp{
-				Order:  order.Number,
-				Status: models.StatusInvalid,
-			}
-			if err = as.repo.UpdateOrderStatusAndAccrual(ctx, upd); err != nil {
-				as.logger.Error("failed to update order to INVALID", zap.String("order", order.Number), zap.Error(err))
-				continue
-			}
-			processed++
+func (as *AccrualService) handleResult(ctx context.Context, order *models.Order, accrualResp *models.AccrualResp) pollOutcome {
+	if accrualResp == nil {
+		as.scheduleRetry(ctx, order)
+		return pollRescheduled
+	}
 
-		case models.StatusProcessed:
-			upd := &models.AccrualResp{
-				Order:   order.Number,
-				Status:  models.StatusProcessed,
-				Accrual: accrualResp.Accrual,
-			}
-			if err = as.repo.UpdateOrderStatusAndAccrual(ctx, upd); err != nil {
-				as.logger.Error("failed to update order to PROCESSED", zap.String("order", order.Number), zap.Error(err))
-				continue
+	switch accrualResp.Status {
+	case models.StatusRegistered, models.StatusProcessing:
+		as.schedulePoll(ctx, order)
+		return pollUpdated
+
+	case models.StatusInvalid, models.StatusProcessed:
+		upd := finalUpdate(order.Number, accrualResp)
+		if _, err := as.repo.ApplyOrderAccrual(ctx, as.opts.Owner, upd); err != nil {
+			if errors.Is(err, models.ErrOrderLeaseLost) {
+				logctx.FromContext(ctx, as.logger).Info("order was updated concurrently, poll result discarded", zap.String("order", order.Number))
+				return pollRescheduled
 			}
-			processed++
+			logctx.FromContext(ctx, as.logger).Error("failed to update order to "+upd.Status, zap.String("order", order.Number), zap.Error(err))
+			return pollRescheduled
+		}
+		if upd.Status == models.StatusProcessed {
+			metrics.PointsAccrued.Add(upd.Accrual)
+		}
+		return pollUpdated
 
-		default:
-			as.logger.Error("unexpected accrual status", zap.String("order", order.Number), zap.String("status", accrualResp.Status))
-			continue
+	default:
+		logctx.FromContext(ctx, as.logger).Error("unexpected accrual status", zap.String("order", order.Number), zap.String("status", accrualResp.Status))
+		as.scheduleRetry(ctx, order)
+		return pollRescheduled
+	}
+}
+
+func groupByProvider(orders []models.Order) map[string][]models.Order {
+	groups := make(map[string][]models.Order)
+	for _, order := range orders {
+		groups[order.Provider] = append(groups[order.Provider], order)
+	}
+	return groups
+}
+
+func (as *AccrualService) ApplyAccrual(ctx context.Context, accrualResp *models.AccrualResp) error {
+	ctx, span := tracing.Start(ctx, "AccrualService.ApplyAccrual")
+	defer span.End()
+
+	var upd *models.AccrualResp
+	switch accrualResp.Status {
+	case models.StatusRegistered, models.StatusProcessing:
+		upd = &models.AccrualResp{Order: accrualResp.Order, Status: models.StatusProcessing}
+	case models.StatusInvalid, models.StatusProcessed:
+		upd = finalUpdate(accrualResp.Order, accrualResp)
+	default:
+		return models.ErrAccrualStatusInvalid
+	}
+
+	applied, err := as.repo.ApplyOrderAccrual(ctx, "", upd)
+	if err != nil {
+		return fmt.Errorf("failed to apply accrual: %w", err)
+	}
+	if applied && upd.Status == models.StatusProcessed {
+		metrics.PointsAccrued.Add(upd.Accrual)
+	}
+	return nil
+}
+
+func finalUpdate(number string, accrualResp *models.AccrualResp) *models.AccrualResp {
+	upd := &models.AccrualResp{
+		Order:  number,
+		Status: accrualResp.Status,
+	}
+	if accrualResp.Status == models.StatusProcessed {
+		upd.Accrual = accrualResp.Accrual
+	}
+	return upd
+}
+
+func (as *AccrualService) throttle(p *AccrualProvider, e *models.AccrualThrottledError) {
+	prev := p.Limiter.Rate()
+	p.Limiter.OnThrottle(e.RetryAfter, e.LimitPerMinute)
+	as.logger.Info("accrual rate limited",
+		zap.String("provider", p.Name),
+		zap.Duration("retry_after", e.RetryAfter),
+		zap.Int("limit_per_minute", e.LimitPerMinute),
+		zap.Float64("prev_rps", prev),
+		zap.Float64("rps", p.Limiter.Rate()),
+	)
+}
+
+func (as *AccrualService) schedulePoll(ctx context.Context, order *models.Order) {
+	next := time.Now().Add(as.opts.Poll.Backoff(0))
+	if err := as.repo.ScheduleOrderPoll(ctx, as.opts.Owner, order.ID, 0, next); err != nil {
+		logctx.FromContext(ctx, as.logger).Error("failed to schedule order poll", zap.String("order", order.Number), zap.Error(err))
+	}
+}
+
+func (as *AccrualService) scheduleRetry(ctx context.Context, order *models.Order) {
+	attempts := order.Attempts + 1
+
+	if as.opts.Poll.MaxAttempts > 0 && attempts >= as.opts.Poll.MaxAttempts {
+		if err := as.repo.ParkOrder(ctx, as.opts.Owner, order.ID, attempts); err != nil {
+			logctx.FromContext(ctx, as.logger).Error("failed to park order", zap.String("order", order.Number), zap.Error(err))
+			return
 		}
+		logctx.FromContext(ctx, as.logger).Warn("order parked after max poll attempts", zap.String("order", order.Number), zap.Int("attempts", attempts))
+		return
+	}
+
+	next := time.Now().Add(as.opts.Poll.Backoff(attempts))
+	if err := as.repo.ScheduleOrderPoll(ctx, as.opts.Owner, order.ID, attempts, next); err != nil {
+		logctx.FromContext(ctx, as.logger).Error("failed to schedule order poll", zap.String("order", order.Number), zap.Error(err))
 	}
+}
+
+func (as *AccrualService) startLeaseHeartbeat(ctx context.Context, lease *leaseSet) func() {
+	hbCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(as.opts.LeaseTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-hbCtx.Done():
+				return
+			case <-ticker.C:
+				if err := as.repo.ExtendOrderLeases(hbCtx, as.opts.Owner, lease.pending(), as.opts.LeaseTTL); err != nil && hbCtx.Err() == nil {
+					logctx.FromContext(hbCtx, as.logger).Warn("failed to extend order leases", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
 
-	return processed, 0, nil
+func (as *AccrualService) releaseLeases(ctx context.Context, lease *leaseSet) {
+	ids := lease.pending()
+	if len(ids) == 0 {
+		return
+	}
+
+	relCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancel()
+
+	if err := as.repo.ReleaseOrderLeases(relCtx, as.opts.Owner, ids); err != nil {
+		logctx.FromContext(ctx, as.logger).Warn("failed to release order leases", zap.Int("count", len(ids)), zap.Error(err))
+	}
+}
+
+type leaseSet struct {
+	mu  sync.Mutex
+	ids map[int64]struct{}
+}
+
+func newLeaseSet(orders []models.Order) *leaseSet {
+	ids := make(map[int64]struct{}, len(orders))
+	for _, order := range orders {
+		ids[order.ID] = struct{}{}
+	}
+	return &leaseSet{ids: ids}
+}
+
+func (ls *leaseSet) done(id int64) {
+	ls.mu.Lock()
+	delete(ls.ids, id)
+	ls.mu.Unlock()
+}
+
+func (ls *leaseSet) pending() []int64 {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ids := make([]int64, 0, len(ls.ids))
+	for id := range ls.ids {
+		ids = append(ids, id)
+	}
+	return ids
 }
@@ -0,0 +1,471 @@
+package services
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/accrualmock"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/breaker"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/httpclient"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/ratelimit"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"go.uber.org/zap"
+)
+
+type memOrder struct {
+	models.Order
+	claimedBy  string
+	leaseUntil time.Time
+	nextPollAt time.Time
+	parked     bool
+}
+
+type memAccrualRepo struct {
+	mu     sync.Mutex
+	orders []*memOrder
+}
+
+func newMemAccrualRepo(provider string, numbers ...string) *memAccrualRepo {
+	r := &memAccrualRepo{}
+	for i, n := range numbers {
+		r.orders = append(r.orders, &memOrder{Order: models.Order{
+			ID:       int64(i + 1),
+			UserID:   1,
+			Number:   n,
+			Status:   models.StatusNew,
+			Provider: provider,
+		}})
+	}
+	return r
+}
+
+func (r *memAccrualRepo) order(number string) models.Order {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, o := range r.orders {
+		if o.Number == number {
+			return o.Order
+		}
+	}
+	return models.Order{}
+}
+
+func (r *memAccrualRepo) parked(number string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, o := range r.orders {
+		if o.Number == number {
+			return o.parked
+		}
+	}
+	return false
+}
+
+func (r *memAccrualRepo) ClaimOrdersForAccrualPolling(_ context.Context, owner string, providers []string, limit int, ttl time.Duration) ([]models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var claimed []models.Order
+	for _, o := range r.orders {
+		if len(claimed) == limit {
+			break
+		}
+		if o.Status != models.StatusNew && o.Status != models.StatusProcessing {
+			continue
+		}
+		if o.parked || o.nextPollAt.After(now) || o.leaseUntil.After(now) || !slices.Contains(providers, o.Provider) {
+			continue
+		}
+		o.claimedBy, o.leaseUntil = owner, now.Add(ttl)
+		if o.Status == models.StatusNew {
+			o.Status = models.StatusProcessing
+		}
+		claimed = append(claimed, o.Order)
+	}
+	return claimed, nil
+}
+
+func (r *memAccrualRepo) ExtendOrderLeases(_ context.Context, owner string, ids []int64, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, o := range r.orders {
+		if o.claimedBy == owner && slices.Contains(ids, o.ID) {
+			o.leaseUntil = time.Now().Add(ttl)
+		}
+	}
+	return nil
+}
+
+func (r *memAccrualRepo) ReleaseOrderLeases(_ context.Context, owner string, ids []int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, o := range r.orders {
+		if o.claimedBy == owner && slices.Contains(ids, o.ID) {
+			o.claimedBy, o.leaseUntil = "", time.Time{}
+		}
+	}
+	return nil
+}
+
+func (r *memAccrualRepo) leased(owner string, id int64, fn func(o *memOrder)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, o := range r.orders {
+		if o.ID == id && o.claimedBy == owner {
+			fn(o)
+			o.claimedBy, o.leaseUntil = "", time.Time{}
+			return nil
+		}
+	}
+	return models.ErrOrderLeaseLost
+}
+
+func (r *memAccrualRepo) ApplyOrderAccrual(_ context.Context, owner string, accrualResp *models.AccrualResp) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, o := range r.orders {
+		if o.Number != accrualResp.Order {
+			continue
+		}
+		if o.Status != models.StatusNew && o.Status != models.StatusProcessing || owner != "" && o.claimedBy != owner {
+			if owner != "" {
+				return false, models.ErrOrderLeaseLost
+			}
+			return false, nil
+		}
+		o.Status, o.Accrual = accrualResp.Status, accrualResp.Accrual
+		if o.Status == models.StatusProcessed || o.Status == models.StatusInvalid {
+			o.claimedBy, o.leaseUntil, o.parked = "", time.Time{}, false
+		}
+		return true, nil
+	}
+	return false, models.ErrOrderNotFound
+}
+
+func (r *memAccrualRepo) ScheduleOrderPoll(_ context.Context, owner string, id int64, attempts int, nextPollAt time.Time) error {
+	return r.leased(owner, id, func(o *memOrder) {
+		o.Attempts, o.nextPollAt = attempts, nextPollAt
+	})
+}
+
+func (r *memAccrualRepo) ParkOrder(_ context.Context, owner string, id int64, attempts int) error {
+	return r.leased(owner, id, func(o *memOrder) {
+		o.Attempts, o.parked = attempts, true
+	})
+}
+
+func (r *memAccrualRepo) ReassignOrderProviders(_ context.Context, known []string, fallback string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for _, o := range r.orders {
+		if (o.Status == models.StatusNew || o.Status == models.StatusProcessing) && !slices.Contains(known, o.Provider) {
+			o.Provider = fallback
+			n++
+		}
+	}
+	return n, nil
+}
+
+type accrualFixture struct {
+	mock *accrualmock.Server
+	repo *memAccrualRepo
+	svc  *AccrualService
+	lim  *countingLimiter
+	srv  *httptest.Server
+}
+
+type countingLimiter struct {
+	*ratelimit.Limiter
+	successes atomic.Int32
+}
+
+func (l *countingLimiter) OnSuccess() {
+	l.successes.Add(1)
+	l.Limiter.OnSuccess()
+}
+
+func newAccrualFixture(t *testing.T, cfg accrualmock.Config, batchSize, maxAttempts int, numbers ...string) *accrualFixture {
+	t.Helper()
+
+	mock := accrualmock.New(cfg)
+	srv := httptest.NewServer(mock.Handler())
+	t.Cleanup(srv.Close)
+
+	lim := &countingLimiter{Limiter: ratelimit.NewLimiter(1000, 4)}
+	provider := AccrualProvider{
+		Name:    "mock",
+		Client:  httpclient.NewAccrualClient("mock", srv.URL, breaker.New("mock", 100, time.Second, zap.NewNop()), batchSize),
+		Limiter: lim,
+	}
+	repo := newMemAccrualRepo("mock", numbers...)
+	svc := NewAccrualService([]AccrualProvider{provider}, repo, zap.NewNop(), AccrualOptions{
+		BatchSize: 10,
+		Owner:     "test",
+		LeaseTTL:  time.Minute,
+		Poll:      PollPolicy{MaxAttempts: maxAttempts},
+	})
+	return &accrualFixture{mock: mock, repo: repo, svc: svc, lim: lim, srv: srv}
+}
+
+func (f *accrualFixture) poll(t *testing.T, times int) {
+	t.Helper()
+	for range times {
+		if _, err := f.svc.PollAndUpdate(context.Background()); err != nil {
+			t.Fatalf("poll: %v", err)
+		}
+	}
+}
+
+func mockConfig(rules ...accrualmock.Rule) accrualmock.Config {
+	cfg := accrualmock.DefaultConfig()
+	cfg.RetryAfter = 0
+	cfg.Rules = rules
+	return cfg
+}
+
+func TestAccrualServicePollSequences(t *testing.T) {
+	tests := []struct {
+		name        string
+		statuses    []string
+		polls       int
+		wantStatus  string
+		wantAccrual float64
+		wantCalls   int
+		wantTries   int
+	}{
+		{
+			name:        "registered to processed",
+			statuses:    []string{models.StatusRegistered, models.StatusProcessing, models.StatusProcessed},
+			polls:       3,
+			wantStatus:  models.StatusProcessed,
+			wantAccrual: 100,
+			wantCalls:   3,
+		},
+		{
+			name:       "invalid",
+			statuses:   []string{models.StatusRegistered, models.StatusInvalid},
+			polls:      2,
+			wantStatus: models.StatusInvalid,
+			wantCalls:  2,
+		},
+		{
+			name:       "error counter reset by in-progress status",
+			statuses:   []string{accrualmock.StatusError, accrualmock.StatusError, models.StatusProcessing},
+			polls:      2,
+			wantStatus: models.StatusProcessing,
+			wantCalls:  3,
+		},
+		{
+			name:        "throttled then processed",
+			statuses:    []string{accrualmock.StatusTooMany, accrualmock.StatusTooMany, models.StatusProcessed},
+			polls:       3,
+			wantStatus:  models.StatusProcessed,
+			wantAccrual: 100,
+			wantCalls:   3,
+		},
+		{
+			name:        "server errors then processed",
+			statuses:    []string{accrualmock.StatusError, accrualmock.StatusError, models.StatusProcessed},
+			polls:       3,
+			wantStatus:  models.StatusProcessed,
+			wantAccrual: 100,
+			wantCalls:   3,
+			wantTries:   2,
+		},
+		{
+			name:       "not registered",
+			statuses:   []string{accrualmock.StatusNotRegistered},
+			polls:      2,
+			wantStatus: models.StatusProcessing,
+			wantCalls:  3,
+			wantTries:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const number = "12345678903"
+			f := newAccrualFixture(t, mockConfig(accrualmock.Rule{Number: number, Statuses: tt.statuses, Accrual: 100}), 0, 50, number)
+
+			f.poll(t, tt.polls+1)
+
+			o := f.repo.order(number)
+			if o.Status != tt.wantStatus || o.Accrual != tt.wantAccrual {
+				t.Errorf("order is %s/%v, want %s/%v", o.Status, o.Accrual, tt.wantStatus, tt.wantAccrual)
+			}
+			if got := f.mock.Requests(number); got != tt.wantCalls {
+				t.Errorf("accrual called %d times, want %d", got, tt.wantCalls)
+			}
+			if o.Attempts != tt.wantTries {
+				t.Errorf("attempts %d, want %d", o.Attempts, tt.wantTries)
+			}
+		})
+	}
+}
+
+func TestAccrualServiceThrottleSlowsLimiter(t *testing.T) {
+	const number = "12345678903"
+	f := newAccrualFixture(t, mockConfig(accrualmock.Rule{Number: number, Statuses: []string{accrualmock.StatusTooMany, models.StatusProcessed}}), 0, 50, number)
+
+	before := f.lim.Rate()
+	f.poll(t, 1)
+	if after := f.lim.Rate(); after >= before {
+		t.Fatalf("rate %v was not reduced after 429, was %v", after, before)
+	}
+	if o := f.repo.order(number); o.Attempts != 0 {
+		t.Fatalf("429 counted as a failed attempt: %d", o.Attempts)
+	}
+}
+
+func TestAccrualServiceParksAfterMaxAttempts(t *testing.T) {
+	const number = "12345678903"
+	f := newAccrualFixture(t, mockConfig(accrualmock.Rule{Number: number, Statuses: []string{accrualmock.StatusError}}), 0, 3, number)
+
+	f.poll(t, 5)
+	if !f.repo.parked(number) {
+		t.Fatal("order was not parked after repeated 500s")
+	}
+	if got := f.mock.Requests(number); got != 3 {
+		t.Fatalf("accrual called %d times after parking, want 3", got)
+	}
+}
+
+func TestAccrualServiceBatch(t *testing.T) {
+	numbers := []string{"12345678903", "79927398713", "4561261212345467"}
+	cfg := mockConfig(accrualmock.Rule{Number: numbers[1], Statuses: []string{accrualmock.StatusError, models.StatusProcessed}, Accrual: 50})
+	f := newAccrualFixture(t, cfg, 10, 50, numbers...)
+
+	f.poll(t, 4)
+
+	for _, number := range numbers {
+		if o := f.repo.order(number); o.Status != models.StatusProcessed {
+			t.Errorf("order %s is %s, want %s", number, o.Status, models.StatusProcessed)
+		}
+	}
+	for number, want := range map[string]int{numbers[0]: 3, numbers[1]: 2, numbers[2]: 3} {
+		if got := f.mock.Requests(number); got != want {
+			t.Errorf("order %s advanced %d times, want %d", number, got, want)
+		}
+	}
+}
+
+func TestAccrualServiceBatchFallback(t *testing.T) {
+	numbers := []string{"12345678903", "79927398713"}
+	cfg := mockConfig()
+	cfg.DisableBatch = true
+	f := newAccrualFixture(t, cfg, 10, 50, numbers...)
+
+	f.poll(t, 1)
+	for _, number := range numbers {
+		if got := f.mock.Requests(number); got != 1 {
+			t.Fatalf("order %s advanced %d times in the poll that found batching unsupported, want 1", number, got)
+		}
+	}
+
+	f.poll(t, 2)
+
+	for _, number := range numbers {
+		if o := f.repo.order(number); o.Status != models.StatusProcessed || o.Accrual != 100 {
+			t.Errorf("order %s is %s/%v, want %s/100", number, o.Status, o.Accrual, models.StatusProcessed)
+		}
+		if got := f.mock.Requests(number); got != 3 {
+			t.Errorf("order %s advanced %d times, want 3", number, got)
+		}
+	}
+}
+
+func TestAccrualServiceAdoptsOrphanedOrders(t *testing.T) {
+	const number = "12345678903"
+	f := newAccrualFixture(t, mockConfig(), 0, 50, number)
+	f.repo.orders[0].Provider = "removed"
+
+	f.poll(t, 1)
+	if got := f.mock.Requests(number); got != 0 {
+		t.Fatalf("order of unknown provider was polled %d times", got)
+	}
+
+	if err := f.svc.AdoptOrphanedOrders(context.Background(), "mock"); err != nil {
+		t.Fatalf("adopt: %v", err)
+	}
+	f.poll(t, 3)
+	if o := f.repo.order(number); o.Provider != "mock" || o.Status != models.StatusProcessed {
+		t.Fatalf("order is %s/%s, want mock/%s", o.Provider, o.Status, models.StatusProcessed)
+	}
+
+	if err := f.svc.AdoptOrphanedOrders(context.Background(), "removed"); err == nil {
+		t.Fatal("expected error for unknown fallback provider")
+	}
+}
+
+func TestAccrualServiceCallbackWinsOverPoll(t *testing.T) {
+	const number = "12345678903"
+	f := newAccrualFixture(t, mockConfig(), 0, 50, number)
+	ctx := context.Background()
+
+	claimed, _ := f.repo.ClaimOrdersForAccrualPolling(ctx, "test", []string{"mock"}, 10, time.Minute)
+	if len(claimed) != 1 {
+		t.Fatalf("claimed %d orders", len(claimed))
+	}
+	if err := f.svc.ApplyAccrual(ctx, &models.AccrualResp{Order: number, Status: models.StatusProcessed, Accrual: 100}); err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+
+	if got := f.svc.handleResult(ctx, &claimed[0], &models.AccrualResp{Order: number, Status: models.StatusProcessed, Accrual: 42}); got != pollRescheduled {
+		t.Fatalf("stale poll result outcome %v, want rescheduled", got)
+	}
+	if o := f.repo.order(number); o.Status != models.StatusProcessed || o.Accrual != 100 {
+		t.Fatalf("order is %s/%v, want %s/100", o.Status, o.Accrual, models.StatusProcessed)
+	}
+}
+
+func TestAccrualServiceRaisesRateOnlyOnSuccess(t *testing.T) {
+	const number = "12345678903"
+
+	t.Run("server error", func(t *testing.T) {
+		f := newAccrualFixture(t, mockConfig(accrualmock.Rule{Number: number, Statuses: []string{accrualmock.StatusError}}), 0, 50, number)
+		f.poll(t, 3)
+		if got := f.lim.successes.Load(); got != 0 {
+			t.Fatalf("OnSuccess called %d times on 500 responses", got)
+		}
+	})
+
+	t.Run("network error", func(t *testing.T) {
+		f := newAccrualFixture(t, mockConfig(), 0, 50, number)
+		f.srv.Close()
+		f.poll(t, 3)
+		if got := f.lim.successes.Load(); got != 0 {
+			t.Fatalf("OnSuccess called %d times on network errors", got)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		f := newAccrualFixture(t, mockConfig(), 0, 50, number)
+		f.poll(t, 3)
+		if got := f.lim.successes.Load(); got != 3 {
+			t.Fatalf("OnSuccess called %d times, want 3", got)
+		}
+	})
+}
+
+func TestAccrualServiceDoesNotParkInProgressOrders(t *testing.T) {
+	const number = "12345678903"
+	f := newAccrualFixture(t, mockConfig(accrualmock.Rule{Number: number, Statuses: []string{models.StatusProcessing}}), 0, 3, number)
+
+	f.poll(t, 6)
+	if f.repo.parked(number) {
+		t.Fatal("order with valid in-progress status was parked")
+	}
+	if o := f.repo.order(number); o.Attempts != 0 {
+		t.Fatalf("in-progress polls counted as %d failed attempts", o.Attempts)
+	}
+	if got := f.mock.Requests(number); got != 6 {
+		t.Fatalf("accrual called %d times, want 6", got)
+	}
+}
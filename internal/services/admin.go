@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const adminSearchLimit = 50
+
+type AdminRepository interface {
+	SearchUsersByLogin(ctx context.Context, login string, limit int) ([]models.UserInfo, error)
+	GetUserByID(ctx context.Context, id int64) (*models.UserInfo, error)
+	GetOrdersByUserID(ctx context.Context, userID int64) ([]models.Order, error)
+	GetListWithdrawals(ctx context.Context, userID int64) ([]models.Withdrawal, error)
+	GetParkedOrders(ctx context.Context, limit int) ([]models.ParkedOrder, error)
+	ResetOrderForPollingTx(ctx context.Context, tx pgx.Tx, number string) (*models.Order, error)
+	InvalidateOrderTx(ctx context.Context, tx pgx.Tx, number string) (*models.Order, error)
+	GetAvailableBalanceTx(ctx context.Context, tx pgx.Tx, userID int64) (float64, error)
+	CreateAdjustmentTx(ctx context.Context, tx pgx.Tx, userID, actorID int64, adj *models.AdjustmentReq) (int64, error)
+	GetDiscrepancies(ctx context.Context, status string, limit int) ([]models.Discrepancy, error)
+	GetDiscrepancyForUpdateTx(ctx context.Context, tx pgx.Tx, id int64) (*models.Discrepancy, error)
+	ResolveDiscrepancyTx(ctx context.Context, tx pgx.Tx, id int64, status string, adjustmentID, actorID int64) error
+	GetScheduledTasks(ctx context.Context) ([]models.ScheduledTask, error)
+	InsertAuditRecord(ctx context.Context, rec *models.AuditRecord) error
+	InsertAuditRecordTx(ctx context.Context, tx pgx.Tx, rec *models.AuditRecord) error
+	BeginTx(ctx context.Context) (pgx.Tx, error)
+}
+
+type AdminService struct {
+	repo AdminRepository
+}
+
+func NewAdminService(repo AdminRepository) *AdminService {
+	return &AdminService{
+		repo: repo,
+	}
+}
+
+func (as *AdminService) SearchUsers(ctx context.Context, actorID int64, login string) ([]models.UserInfo, error) {
+	users, err := as.repo.SearchUsersByLogin(ctx, login, adminSearchLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	rec := &models.AuditRecord{
+		ActorID: actorID,
+		Action:  "users.search",
+		Details: map[string]any{"login": login, "found": len(users)},
+	}
+	if err = as.repo.InsertAuditRecord(ctx, rec); err != nil {
+		return nil, fmt.Errorf("failed to audit users search: %w", err)
+	}
+	return users, nil
+}
+
+func (as *AdminService) ListUserOrders(ctx context.Context, actorID, userID int64) ([]models.Order, error) {
+	if _, err := as.repo.GetUserByID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	orders, err := as.repo.GetOrdersByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get orders by user_id: %w", err)
+	}
+
+	rec := &models.AuditRecord{
+		ActorID: actorID,
+		Action:  "user.orders.view",
+		Target:  userTarget(userID),
+	}
+	if err = as.repo.InsertAuditRecord(ctx, rec); err != nil {
+		return nil, fmt.Errorf("failed to audit orders view: %w", err)
+	}
+	return orders, nil
+}
+
+func (as *AdminService) ListUserWithdrawals(ctx context.Context, actorID, userID int64) ([]models.Withdrawal, error) {
+	if _, err := as.repo.GetUserByID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	withdrawals, err := as.repo.GetListWithdrawals(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get list of withdrawals: %w", err)
+	}
+
+	rec := &models.AuditRecord{
+		ActorID: actorID,
+		Action:  "user.withdrawals.view",
+		Target:  userTarget(userID),
+	}
+	if err = as.repo.InsertAuditRecord(ctx, rec); err != nil {
+		return nil, fmt.Errorf("failed to audit withdrawals view: %w", err)
+	}
+	return withdrawals, nil
+}
+
+func (as *AdminService) ListParkedOrders(ctx context.Context, actorID int64) ([]models.ParkedOrder, error) {
+	orders, err := as.repo.GetParkedOrders(ctx, adminSearchLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parked orders: %w", err)
+	}
+
+	rec := &models.AuditRecord{
+		ActorID: actorID,
+		Action:  "orders.parked.view",
+	}
+	if err = as.repo.InsertAuditRecord(ctx, rec); err != nil {
+		return nil, fmt.Errorf("failed to audit parked orders view: %w", err)
+	}
+	return orders, nil
+}
+
+func (as *AdminService) RecordLogLevelChange(ctx context.Context, actorID int64, from, to string) error {
+	rec := &models.AuditRecord{
+		ActorID: actorID,
+		Action:  "log.level.change",
+		Details: map[string]any{"from": from, "to": to},
+	}
+	if err := as.repo.InsertAuditRecord(ctx, rec); err != nil {
+		return fmt.Errorf("failed to audit log level change: %w", err)
+	}
+	return nil
+}
+
+func (as *AdminService) ListScheduledTasks(ctx context.Context) ([]models.ScheduledTask, error) {
+	tasks, err := as.repo.GetScheduledTasks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+func (as *AdminService) RepollOrder(ctx context.Context, actorID int64, number string) error {
+	return as.changeOrder(ctx, actorID, "order.repoll", number, as.repo.ResetOrderForPollingTx)
+}
+
+func (as *AdminService) InvalidateOrder(ctx context.Context, actorID int64, number string) error {
+	return as.changeOrder(ctx, actorID, "order.invalidate", number, as.repo.InvalidateOrderTx)
+}
+
+func (as *AdminService) changeOrder(ctx context.Context, actorID int64, action, number string, fn func(ctx context.Context, tx pgx.Tx, number string) (*models.Order, error)) error {
+	rec := &models.AuditRecord{
+		ActorID: actorID,
+		Action:  action,
+		Target:  orderTarget(number),
+	}
+	return as.inTx(ctx, rec, func(tx pgx.Tx) error {
+		prev, err := fn(ctx, tx, number)
+		if err != nil {
+			return err
+		}
+		rec.Details = map[string]any{"prev_status": prev.Status, "prev_accrual": prev.Accrual}
+		return nil
+	})
+}
+
+func (as *AdminService) AdjustBalance(ctx context.Context, actorID, userID int64, adj *models.AdjustmentReq) error {
+	adj.Reason = strings.TrimSpace(adj.Reason)
+	if adj.Amount == 0 || adj.Reason == "" {
+		return models.ErrAdjustmentInvalid
+	}
+
+	return as.inTx(ctx, &models.AuditRecord{
+		ActorID: actorID,
+		Action:  "balance.adjust",
+		Target:  userTarget(userID),
+		Details: map[string]any{"amount": adj.Amount, "reason": adj.Reason},
+	}, func(tx pgx.Tx) error {
+		if adj.Amount < 0 {
+			available, err := as.repo.GetAvailableBalanceTx(ctx, tx, userID)
+			if err != nil {
+				return err
+			}
+			if available+adj.Amount < 0 {
+				return models.ErrAdjustmentExceedsBalance
+			}
+		}
+		_, err := as.repo.CreateAdjustmentTx(ctx, tx, userID, actorID, adj)
+		return err
+	})
+}
+
+func (as *AdminService) ListDiscrepancies(ctx context.Context, actorID int64, status string) ([]models.Discrepancy, error) {
+	discrepancies, err := as.repo.GetDiscrepancies(ctx, status, adminSearchLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discrepancies: %w", err)
+	}
+
+	rec := &models.AuditRecord{
+		ActorID: actorID,
+		Action:  "discrepancies.view",
+		Details: map[string]any{"status": status},
+	}
+	if err = as.repo.InsertAuditRecord(ctx, rec); err != nil {
+		return nil, fmt.Errorf("failed to audit discrepancies view: %w", err)
+	}
+	return discrepancies, nil
+}
+
+func (as *AdminService) CorrectDiscrepancy(ctx context.Context, actorID, id int64) error {
+	tx, err := as.repo.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	d, err := as.repo.GetDiscrepancyForUpdateTx(ctx, tx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get discrepancy: %w", err)
+	}
+	if d.Status != models.DiscrepancyOpen {
+		return models.ErrDiscrepancyResolved
+	}
+
+	delta := math.Round((d.ReportedAccrual-d.ExpectedAccrual)*100) / 100
+	if err = correctDiscrepancyTx(ctx, tx, as.repo, actorID, d.ID, d.UserID, d.Number, delta); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (as *AdminService) DismissDiscrepancy(ctx context.Context, actorID, id int64) error {
+	return as.inTx(ctx, &models.AuditRecord{
+		ActorID: actorID,
+		Action:  "discrepancy.dismiss",
+		Target:  discrepancyTarget(id),
+	}, func(tx pgx.Tx) error {
+		if _, err := as.repo.GetDiscrepancyForUpdateTx(ctx, tx, id); err != nil {
+			return err
+		}
+		return as.repo.ResolveDiscrepancyTx(ctx, tx, id, models.DiscrepancyDismissed, 0, actorID)
+	})
+}
+
+func (as *AdminService) inTx(ctx context.Context, rec *models.AuditRecord, fn func(tx pgx.Tx) error) error {
+	tx, err := as.repo.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = fn(tx); err != nil {
+		return fmt.Errorf("failed to perform %s: %w", rec.Action, err)
+	}
+
+	if err = as.repo.InsertAuditRecordTx(ctx, tx, rec); err != nil {
+		return fmt.Errorf("failed to audit %s: %w", rec.Action, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func userTarget(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+func orderTarget(number string) string {
+	return "order:" + number
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/time/rate"
+)
+
+const (
+	apiKeyPrefix       = "gm_"
+	apiKeyPrefixLen    = len(apiKeyPrefix) + 8
+	defaultKeyRPMLimit = 60
+)
+
+var knownScopes = []string{
+	models.ScopeOrdersRead,
+	models.ScopeOrdersWrite,
+	models.ScopeBalanceRead,
+	models.ScopeBalanceWrite,
+}
+
+type APIKeysRepository interface {
+	CreateAPIKeyTx(ctx context.Context, tx pgx.Tx, actorID int64, prefix string, keyHash []byte, req *models.APIKeyReq) (*models.APIKey, error)
+	UpdateAPIKeyTx(ctx context.Context, tx pgx.Tx, id int64, req *models.APIKeyReq) (*models.APIKey, error)
+	RevokeAPIKeyTx(ctx context.Context, tx pgx.Tx, id int64) error
+	GetAPIKeyByID(ctx context.Context, id int64) (*models.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash []byte) (*models.APIKey, error)
+	GetListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int64) error
+	InsertAuditRecordTx(ctx context.Context, tx pgx.Tx, rec *models.AuditRecord) error
+	BeginTx(ctx context.Context) (pgx.Tx, error)
+}
+
+type keyLimiter struct {
+	limit   int
+	limiter *rate.Limiter
+}
+
+type APIKeysService struct {
+	repo     APIKeysRepository
+	mu       sync.Mutex
+	limiters map[int64]*keyLimiter
+}
+
+func NewAPIKeysService(repo APIKeysRepository) *APIKeysService {
+	return &APIKeysService{
+		repo:     repo,
+		limiters: make(map[int64]*keyLimiter),
+	}
+}
+
+func (ks *APIKeysService) AuthenticateKey(ctx context.Context, rawKey string) (*models.APIKey, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, models.ErrAPIKeyInvalid
+	}
+
+	key, err := ks.repo.GetAPIKeyByHash(ctx, hashAPIKey(rawKey))
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			return nil, models.ErrAPIKeyInvalid
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	if key.RevokedAt != nil {
+		return nil, models.ErrAPIKeyInvalid
+	}
+
+	if !ks.allow(key) {
+		return nil, models.ErrAPIKeyRateLimited
+	}
+
+	if err = ks.repo.TouchAPIKey(ctx, key.ID); err != nil {
+		return nil, fmt.Errorf("failed to update api key last usage: %w", err)
+	}
+
+	return key, nil
+}
+
+func (ks *APIKeysService) CreateKey(ctx context.Context, actorID int64, req *models.APIKeyReq) (*models.APIKeyCreated, error) {
+	if err := normalizeAPIKeyReq(req); err != nil {
+		return nil, err
+	}
+
+	rawKey, err := generateAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	tx, err := ks.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	key, err := ks.repo.CreateAPIKeyTx(ctx, tx, actorID, rawKey[:apiKeyPrefixLen], hashAPIKey(rawKey), req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	rec := &models.AuditRecord{
+		ActorID: actorID,
+		Action:  "api_key.create",
+		Target:  apiKeyTarget(key.ID),
+		Details: map[string]any{"name": key.Name, "scopes": key.Scopes, "user_ids": key.UserIDs, "rate_limit": key.RateLimit},
+	}
+	if err = ks.repo.InsertAuditRecordTx(ctx, tx, rec); err != nil {
+		return nil, fmt.Errorf("failed to audit api key creation: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &models.APIKeyCreated{APIKey: *key, Key: rawKey}, nil
+}
+
+func (ks *APIKeysService) UpdateKey(ctx context.Context, actorID, id int64, req *models.APIKeyReq) (*models.APIKey, error) {
+	if err := normalizeAPIKeyReq(req); err != nil {
+		return nil, err
+	}
+
+	tx, err := ks.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	key, err := ks.repo.UpdateAPIKeyTx(ctx, tx, id, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update api key: %w", err)
+	}
+
+	rec := &models.AuditRecord{
+		ActorID: actorID,
+		Action:  "api_key.update",
+		Target:  apiKeyTarget(id),
+		Details: map[string]any{"name": key.Name, "scopes": key.Scopes, "user_ids": key.UserIDs, "rate_limit": key.RateLimit},
+	}
+	if err = ks.repo.InsertAuditRecordTx(ctx, tx, rec); err != nil {
+		return nil, fmt.Errorf("failed to audit api key update: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return key, nil
+}
+
+func (ks *APIKeysService) RevokeKey(ctx context.Context, actorID, id int64) error {
+	tx, err := ks.repo.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = ks.repo.RevokeAPIKeyTx(ctx, tx, id); err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	rec := &models.AuditRecord{
+		ActorID: actorID,
+		Action:  "api_key.revoke",
+		Target:  apiKeyTarget(id),
+	}
+	if err = ks.repo.InsertAuditRecordTx(ctx, tx, rec); err != nil {
+		return fmt.Errorf("failed to audit api key revocation: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	ks.mu.Lock()
+	delete(ks.limiters, id)
+	ks.mu.Unlock()
+	return nil
+}
+
+func (ks *APIKeysService) GetKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	key, err := ks.repo.GetAPIKeyByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return key, nil
+}
+
+func (ks *APIKeysService) ListKeys(ctx context.Context) ([]models.APIKey, error) {
+	keys, err := ks.repo.GetListAPIKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get list of api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (ks *APIKeysService) allow(key *models.APIKey) bool {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	kl, ok := ks.limiters[key.ID]
+	if !ok || kl.limit != key.RateLimit {
+		kl = &keyLimiter{
+			limit:   key.RateLimit,
+			limiter: rate.NewLimiter(rate.Limit(float64(key.RateLimit)/60), key.RateLimit),
+		}
+		ks.limiters[key.ID] = kl
+	}
+	return kl.limiter.Allow()
+}
+
+func normalizeAPIKeyReq(req *models.APIKeyReq) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Scopes) == 0 || len(req.UserIDs) == 0 {
+		return models.ErrAPIKeyReqInvalid
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(knownScopes, scope) {
+			return models.ErrAPIKeyReqInvalid
+		}
+	}
+	for _, id := range req.UserIDs {
+		if id <= 0 {
+			return models.ErrAPIKeyReqInvalid
+		}
+	}
+	if req.RateLimit < 0 {
+		return models.ErrAPIKeyReqInvalid
+	}
+	if req.RateLimit == 0 {
+		req.RateLimit = defaultKeyRPMLimit
+	}
+	return nil
+}
+
+func generateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + hex.EncodeToString(b), nil
+}
+
+func hashAPIKey(rawKey string) []byte {
+	sum := sha256.Sum256([]byte(rawKey))
+	return sum[:]
+}
+
+func apiKeyTarget(id int64) string {
+	return "api_key:" + strconv.FormatInt(id, 10)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/metrics"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/tracing"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -14,7 +16,7 @@ type UsersRepository interface {
 }
 
 type TokenGenerator interface {
-	Generate(id int64) (string, error)
+	Generate(id int64, role string) (string, error)
 }
 type AuthService struct {
 	repo   UsersRepository
@@ -29,7 +31,10 @@ func NewAuthService(repo UsersRepository, tg TokenGenerator) *AuthService {
 }
 
 func (as *AuthService) RegisterUser(ctx context.Context, creds *models.Creds) (string, error) {
-	passHash, err := hashPassword(creds.Password)
+	ctx, span := tracing.Start(ctx, "AuthService.RegisterUser")
+	defer span.End()
+
+	passHash, err := hashPassword(ctx, creds.Password)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
@@ -38,8 +43,9 @@ func (as *AuthService) RegisterUser(ctx context.Context, creds *models.Creds) (s
 	if err != nil {
 		return "", fmt.Errorf("failed to create user: %w", err)
 	}
+	metrics.Registrations.Inc()
 
-	token, err := as.tokens.Generate(id)
+	token, err := as.tokens.Generate(id, models.RoleUser)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate JWT: %w", err)
 	}
@@ -48,16 +54,19 @@ func (as *AuthService) RegisterUser(ctx context.Context, creds *models.Creds) (s
 }
 
 func (as *AuthService) AuthenticateUser(ctx context.Context, creds *models.Creds) (string, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.AuthenticateUser")
+	defer span.End()
+
 	user, err := as.repo.GetUserByLogin(ctx, creds.Login)
 	if err != nil {
 		return "", fmt.Errorf("failed to get user: %w", err)
 	}
 
-	if err = checkPasswordHash(user.PasswordHash, creds.Password); err != nil {
+	if err = checkPasswordHash(ctx, user.PasswordHash, creds.Password); err != nil {
 		return "", models.ErrUserInvalidCredentials
 	}
 
-	token, err := as.tokens.Generate(user.ID)
+	token, err := as.tokens.Generate(user.ID, user.Role)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate JWT: %w", err)
 	}
@@ -65,10 +74,16 @@ func (as *AuthService) AuthenticateUser(ctx context.Context, creds *models.Creds
 	return token, nil
 }
 
-func hashPassword(password string) ([]byte, error) {
+func hashPassword(ctx context.Context, password string) ([]byte, error) {
+	_, span := tracing.Start(ctx, "bcrypt.GenerateFromPassword")
+	defer span.End()
+
 	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 }
 
-func checkPasswordHash(passHash []byte, password string) error {
+func checkPasswordHash(ctx context.Context, passHash []byte, password string) error {
+	_, span := tracing.Start(ctx, "bcrypt.CompareHashAndPassword")
+	defer span.End()
+
 	return bcrypt.CompareHashAndPassword(passHash, []byte(password))
 }
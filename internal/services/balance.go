@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/metrics"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/tracing"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
 	"github.com/jackc/pgx/v5"
 )
@@ -26,6 +28,9 @@ func NewBalanceService(repo BalanceRepository) *BalanceService {
 }
 
 func (bs *BalanceService) CalculateBalance(ctx context.Context, userID int64) (*models.Balance, error) {
+	ctx, span := tracing.Start(ctx, "BalanceService.CalculateBalance")
+	defer span.End()
+
 	balance, err := bs.repo.GetBalanceByUserID(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to calculate balance: %w", err)
@@ -34,6 +39,9 @@ func (bs *BalanceService) CalculateBalance(ctx context.Context, userID int64) (*
 }
 
 func (bs *BalanceService) WithdrawFunds(ctx context.Context, userID int64, wd *models.WithdrawReq) error {
+	ctx, span := tracing.Start(ctx, "BalanceService.WithdrawFunds")
+	defer span.End()
+
 	tx, err := bs.repo.BeginTx(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -47,10 +55,14 @@ func (bs *BalanceService) WithdrawFunds(ctx context.Context, userID int64, wd *m
 	if err = tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	metrics.PointsWithdrawn.Add(wd.Sum)
 	return nil
 }
 
 func (bs *BalanceService) ListWithdrawals(ctx context.Context, userID int64) ([]models.Withdrawal, error) {
+	ctx, span := tracing.Start(ctx, "BalanceService.ListWithdrawals")
+	defer span.End()
+
 	list, err := bs.repo.GetListWithdrawals(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get list of withdrawals: %w", err)
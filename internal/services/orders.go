@@ -5,27 +5,38 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/metrics"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/tracing"
 	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
 )
 
+type OrderRouter interface {
+	Route(number string, apiKeyID int64) string
+}
+
 type OrdersRepository interface {
-	InsertOrder(ctx context.Context, userID int64, num string) error
+	InsertOrder(ctx context.Context, userID int64, num, provider string) error
 	GetOrderOwnerID(ctx context.Context, num string) (int64, error)
 	GetOrdersByUserID(ctx context.Context, userID int64) ([]models.Order, error)
 }
 
 type OrdersService struct {
-	repo OrdersRepository
+	repo   OrdersRepository
+	router OrderRouter
 }
 
-func NewOrdersService(repo OrdersRepository) *OrdersService {
+func NewOrdersService(repo OrdersRepository, router OrderRouter) *OrdersService {
 	return &OrdersService{
-		repo: repo,
+		repo:   repo,
+		router: router,
 	}
 }
 
-func (os *OrdersService) LoadOrder(ctx context.Context, userID int64, num string) error {
-	if err := os.repo.InsertOrder(ctx, userID, num); err != nil {
+func (os *OrdersService) LoadOrder(ctx context.Context, userID, apiKeyID int64, num string) error {
+	ctx, span := tracing.Start(ctx, "OrdersService.LoadOrder")
+	defer span.End()
+
+	if err := os.repo.InsertOrder(ctx, userID, num, os.router.Route(num, apiKeyID)); err != nil {
 		if errors.Is(err, models.ErrOrderExists) {
 			ownerID, err := os.repo.GetOrderOwnerID(ctx, num)
 			if err != nil {
@@ -38,10 +49,14 @@ func (os *OrdersService) LoadOrder(ctx context.Context, userID int64, num string
 		}
 		return fmt.Errorf("failed to load order: %w", err)
 	}
+	metrics.OrdersUploaded.Inc()
 	return nil
 }
 
 func (os *OrdersService) ListOrders(ctx context.Context, userID int64) ([]models.Order, error) {
+	ctx, span := tracing.Start(ctx, "OrdersService.ListOrders")
+	defer span.End()
+
 	orders, err := os.repo.GetOrdersByUserID(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get orders by user_id: %w", err)
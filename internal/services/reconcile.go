@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/logctx"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/tracing"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+type ReconcileRepository interface {
+	GetOrdersForReconciliation(ctx context.Context, since time.Time, providers []string, limit int) ([]models.Order, error)
+	MarkOrderReconciled(ctx context.Context, id int64) error
+	UpsertDiscrepancyTx(ctx context.Context, tx pgx.Tx, d *models.Discrepancy) (int64, error)
+	ResolveDiscrepancyTx(ctx context.Context, tx pgx.Tx, id int64, status string, adjustmentID, actorID int64) error
+	CreateAdjustmentTx(ctx context.Context, tx pgx.Tx, userID, actorID int64, adj *models.AdjustmentReq) (int64, error)
+	InsertAuditRecordTx(ctx context.Context, tx pgx.Tx, rec *models.AuditRecord) error
+	BeginTx(ctx context.Context) (pgx.Tx, error)
+}
+
+type ReconcileOptions struct {
+	Window      time.Duration
+	BatchSize   int
+	AutoCorrect bool
+}
+
+type ReconcileService struct {
+	providers map[string]*AccrualProvider
+	repo      ReconcileRepository
+	logger    *zap.Logger
+	opts      ReconcileOptions
+}
+
+func NewReconcileService(providers []AccrualProvider, repo ReconcileRepository, logger *zap.Logger, opts ReconcileOptions) *ReconcileService {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 50
+	}
+	byName := make(map[string]*AccrualProvider, len(providers))
+	for i := range providers {
+		byName[providers[i].Name] = &providers[i]
+	}
+	return &ReconcileService{
+		providers: byName,
+		repo:      repo,
+		logger:    logger.With(zap.String("service", "reconcile")),
+		opts:      opts,
+	}
+}
+
+func (rs *ReconcileService) Reconcile(ctx context.Context) (int, int, error) {
+	ctx, span := tracing.Start(ctx, "ReconcileService.Reconcile")
+	defer span.End()
+
+	var ready []string
+	for name, p := range rs.providers {
+		if p.Client.Ready() {
+			ready = append(ready, name)
+		}
+	}
+	if len(ready) == 0 {
+		return 0, 0, models.ErrAccrualUnavailable
+	}
+
+	orders, err := rs.repo.GetOrdersForReconciliation(ctx, time.Now().Add(-rs.opts.Window), ready, rs.opts.BatchSize)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get orders for reconciliation: %w", err)
+	}
+
+	var checked, found int
+	for i := range orders {
+		order := &orders[i]
+		p := rs.providers[order.Provider]
+
+		accrualResp, err := rs.fetch(ctx, p, order.Number)
+		if err != nil {
+			var throttled *models.AccrualThrottledError
+			switch {
+			case ctx.Err() != nil:
+				return checked, found, ctx.Err()
+			case errors.As(err, &throttled):
+				p.Limiter.OnThrottle(throttled.RetryAfter, throttled.LimitPerMinute)
+				return checked, found, nil
+			case errors.Is(err, models.ErrAccrualUnavailable):
+				continue
+			case !errors.Is(err, models.ErrAccrualOrderNotRegistered):
+				logctx.FromContext(ctx, rs.logger).Warn("failed to query accrual for reconciliation", zap.String("order", order.Number), zap.Error(err))
+				continue
+			}
+			logctx.FromContext(ctx, rs.logger).Warn("processed order is not registered in accrual system", zap.String("provider", p.Name), zap.String("order", order.Number))
+			accrualResp = &models.AccrualResp{Order: order.Number, Status: models.StatusNotRegistered}
+		}
+		checked++
+
+		ok, err := rs.compare(ctx, order, accrualResp)
+		if err != nil {
+			logctx.FromContext(ctx, rs.logger).Error("failed to record discrepancy", zap.String("order", order.Number), zap.Error(err))
+			continue
+		}
+		if !ok {
+			found++
+		}
+
+		if err = rs.repo.MarkOrderReconciled(ctx, order.ID); err != nil {
+			return checked, found, fmt.Errorf("failed to mark order reconciled: %w", err)
+		}
+	}
+	return checked, found, nil
+}
+
+func (rs *ReconcileService) fetch(ctx context.Context, p *AccrualProvider, number string) (*models.AccrualResp, error) {
+	release, err := p.Limiter.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	accrualResp, err := p.Client.GetOrderAccrual(ctx, number)
+	if err == nil || errors.Is(err, models.ErrAccrualOrderNotRegistered) {
+		p.Limiter.OnSuccess()
+	}
+	return accrualResp, err
+}
+
+func (rs *ReconcileService) compare(ctx context.Context, order *models.Order, accrualResp *models.AccrualResp) (bool, error) {
+	var reported float64
+	switch accrualResp.Status {
+	case models.StatusProcessed:
+		reported = accrualResp.Accrual
+	case models.StatusInvalid, models.StatusNotRegistered:
+	default:
+		logctx.FromContext(ctx, rs.logger).Warn("processed order reported in non-final status", zap.String("order", order.Number), zap.String("status", accrualResp.Status))
+		return true, nil
+	}
+
+	notRegistered := accrualResp.Status == models.StatusNotRegistered
+	delta := math.Round((reported-order.Accrual)*100) / 100
+	if delta == 0 && !notRegistered {
+		return true, nil
+	}
+
+	tx, err := rs.repo.BeginTx(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	id, err := rs.repo.UpsertDiscrepancyTx(ctx, tx, &models.Discrepancy{
+		OrderID:         order.ID,
+		ExpectedAccrual: order.Accrual,
+		ReportedStatus:  accrualResp.Status,
+		ReportedAccrual: reported,
+	})
+	if err != nil {
+		return false, err
+	}
+	if id == 0 {
+		return true, nil
+	}
+
+	logctx.FromContext(ctx, rs.logger).Warn("accrual discrepancy detected",
+		zap.String("order", order.Number),
+		zap.Float64("expected", order.Accrual),
+		zap.String("reported_status", accrualResp.Status),
+		zap.Float64("reported", reported),
+	)
+
+	if rs.opts.AutoCorrect && !notRegistered {
+		if err = correctDiscrepancyTx(ctx, tx, rs.repo, 0, id, order.UserID, order.Number, delta); err != nil {
+			return false, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return false, nil
+}
+
+type discrepancyCorrector interface {
+	CreateAdjustmentTx(ctx context.Context, tx pgx.Tx, userID, actorID int64, adj *models.AdjustmentReq) (int64, error)
+	ResolveDiscrepancyTx(ctx context.Context, tx pgx.Tx, id int64, status string, adjustmentID, actorID int64) error
+	InsertAuditRecordTx(ctx context.Context, tx pgx.Tx, rec *models.AuditRecord) error
+}
+
+func correctDiscrepancyTx(ctx context.Context, tx pgx.Tx, repo discrepancyCorrector, actorID, id, userID int64, number string, delta float64) error {
+	adj := &models.AdjustmentReq{
+		Amount: delta,
+		Reason: "accrual reconciliation for order " + number,
+	}
+	adjID, err := repo.CreateAdjustmentTx(ctx, tx, userID, actorID, adj)
+	if err != nil {
+		return fmt.Errorf("failed to create adjustment: %w", err)
+	}
+
+	if err = repo.ResolveDiscrepancyTx(ctx, tx, id, models.DiscrepancyCorrected, adjID, actorID); err != nil {
+		return fmt.Errorf("failed to resolve discrepancy: %w", err)
+	}
+
+	rec := &models.AuditRecord{
+		ActorID: actorID,
+		Action:  "discrepancy.correct",
+		Target:  discrepancyTarget(id),
+		Details: map[string]any{"order": number, "amount": delta, "adjustment_id": adjID},
+	}
+	if err = repo.InsertAuditRecordTx(ctx, tx, rec); err != nil {
+		return fmt.Errorf("failed to audit discrepancy correction: %w", err)
+	}
+	return nil
+}
+
+func discrepancyTarget(id int64) string {
+	return "discrepancy:" + strconv.FormatInt(id, 10)
+}
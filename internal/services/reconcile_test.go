@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/accrualmock"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/breaker"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/httpclient"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/ratelimit"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+type fakeTx struct {
+	pgx.Tx
+	repo *fakeReconcileRepo
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	tx.repo.commits++
+	return nil
+}
+
+func (tx *fakeTx) Rollback(context.Context) error { return nil }
+
+type fakeReconcileRepo struct {
+	orders        []models.Order
+	reconciled    []int64
+	discrepancies []models.Discrepancy
+	adjustments   []models.AdjustmentReq
+	resolved      map[int64]string
+	audits        []string
+	commits       int
+}
+
+func (r *fakeReconcileRepo) GetOrdersForReconciliation(context.Context, time.Time, []string, int) ([]models.Order, error) {
+	return r.orders, nil
+}
+
+func (r *fakeReconcileRepo) MarkOrderReconciled(_ context.Context, id int64) error {
+	r.reconciled = append(r.reconciled, id)
+	return nil
+}
+
+func (r *fakeReconcileRepo) UpsertDiscrepancyTx(_ context.Context, _ pgx.Tx, d *models.Discrepancy) (int64, error) {
+	r.discrepancies = append(r.discrepancies, *d)
+	return int64(len(r.discrepancies)), nil
+}
+
+func (r *fakeReconcileRepo) ResolveDiscrepancyTx(_ context.Context, _ pgx.Tx, id int64, status string, _, _ int64) error {
+	r.resolved[id] = status
+	return nil
+}
+
+func (r *fakeReconcileRepo) CreateAdjustmentTx(_ context.Context, _ pgx.Tx, _, _ int64, adj *models.AdjustmentReq) (int64, error) {
+	r.adjustments = append(r.adjustments, *adj)
+	return int64(len(r.adjustments)), nil
+}
+
+func (r *fakeReconcileRepo) InsertAuditRecordTx(_ context.Context, _ pgx.Tx, rec *models.AuditRecord) error {
+	r.audits = append(r.audits, rec.Action)
+	return nil
+}
+
+func (r *fakeReconcileRepo) BeginTx(context.Context) (pgx.Tx, error) {
+	return &fakeTx{repo: r}, nil
+}
+
+func reconcileOrder(id int64, number string, accrual float64) models.Order {
+	return models.Order{ID: id, UserID: 1, Number: number, Status: models.StatusProcessed, Accrual: accrual, Provider: "mock"}
+}
+
+func newReconcileFixture(t *testing.T, autoCorrect bool, rules []accrualmock.Rule, orders ...models.Order) (*ReconcileService, *fakeReconcileRepo) {
+	t.Helper()
+
+	srv := httptest.NewServer(accrualmock.New(mockConfig(rules...)).Handler())
+	t.Cleanup(srv.Close)
+
+	provider := AccrualProvider{
+		Name:    "mock",
+		Client:  httpclient.NewAccrualClient("mock", srv.URL, breaker.New("mock", 100, time.Second, zap.NewNop()), 0),
+		Limiter: ratelimit.NewLimiter(1000, 4),
+	}
+	repo := &fakeReconcileRepo{orders: orders, resolved: make(map[int64]string)}
+	svc := NewReconcileService([]AccrualProvider{provider}, repo, zap.NewNop(), ReconcileOptions{
+		Window:      time.Hour,
+		AutoCorrect: autoCorrect,
+	})
+	return svc, repo
+}
+
+func TestReconcileServiceEqualAmounts(t *testing.T) {
+	svc, repo := newReconcileFixture(t, true,
+		[]accrualmock.Rule{{Number: "12345678903", Statuses: []string{models.StatusProcessed}, Accrual: 100}},
+		reconcileOrder(1, "12345678903", 100),
+	)
+
+	checked, found, err := svc.Reconcile(context.Background())
+	if err != nil || checked != 1 || found != 0 {
+		t.Fatalf("got checked=%d found=%d err=%v, want 1/0/nil", checked, found, err)
+	}
+	if len(repo.discrepancies) != 0 || len(repo.adjustments) != 0 {
+		t.Fatalf("unexpected discrepancies %+v, adjustments %+v", repo.discrepancies, repo.adjustments)
+	}
+	if len(repo.reconciled) != 1 || repo.reconciled[0] != 1 {
+		t.Fatalf("reconciled %v, want [1]", repo.reconciled)
+	}
+}
+
+func TestReconcileServiceDifferingAmount(t *testing.T) {
+	rules := []accrualmock.Rule{{Number: "12345678903", Statuses: []string{models.StatusProcessed}, Accrual: 150}}
+
+	t.Run("review", func(t *testing.T) {
+		svc, repo := newReconcileFixture(t, false, rules, reconcileOrder(1, "12345678903", 100))
+
+		checked, found, err := svc.Reconcile(context.Background())
+		if err != nil || checked != 1 || found != 1 {
+			t.Fatalf("got checked=%d found=%d err=%v, want 1/1/nil", checked, found, err)
+		}
+		if len(repo.discrepancies) != 1 {
+			t.Fatalf("got %d discrepancies, want 1", len(repo.discrepancies))
+		}
+		d := repo.discrepancies[0]
+		if d.OrderID != 1 || d.ExpectedAccrual != 100 || d.ReportedAccrual != 150 || d.ReportedStatus != models.StatusProcessed {
+			t.Fatalf("discrepancy %+v", d)
+		}
+		if len(repo.adjustments) != 0 || len(repo.resolved) != 0 {
+			t.Fatalf("review mode corrected the balance: adjustments %+v", repo.adjustments)
+		}
+		if repo.commits != 1 || len(repo.reconciled) != 1 {
+			t.Fatalf("commits %d, reconciled %v", repo.commits, repo.reconciled)
+		}
+	})
+
+	t.Run("auto-correct", func(t *testing.T) {
+		svc, repo := newReconcileFixture(t, true, rules, reconcileOrder(1, "12345678903", 100))
+
+		if _, found, err := svc.Reconcile(context.Background()); err != nil || found != 1 {
+			t.Fatalf("got found=%d err=%v, want 1/nil", found, err)
+		}
+		if len(repo.adjustments) != 1 || repo.adjustments[0].Amount != 50 {
+			t.Fatalf("adjustments %+v, want one of 50", repo.adjustments)
+		}
+		if repo.resolved[1] != models.DiscrepancyCorrected {
+			t.Fatalf("discrepancy status %q, want %s", repo.resolved[1], models.DiscrepancyCorrected)
+		}
+		if len(repo.audits) != 1 || repo.audits[0] != "discrepancy.correct" {
+			t.Fatalf("audit records %v", repo.audits)
+		}
+	})
+}
+
+func TestReconcileServiceNotRegistered(t *testing.T) {
+	for _, autoCorrect := range []bool{false, true} {
+		svc, repo := newReconcileFixture(t, autoCorrect,
+			[]accrualmock.Rule{{Number: "12345678903", Statuses: []string{accrualmock.StatusNotRegistered}}},
+			reconcileOrder(1, "12345678903", 100),
+		)
+
+		checked, found, err := svc.Reconcile(context.Background())
+		if err != nil || checked != 1 || found != 1 {
+			t.Fatalf("auto-correct %v: got checked=%d found=%d err=%v, want 1/1/nil", autoCorrect, checked, found, err)
+		}
+		if len(repo.discrepancies) != 1 {
+			t.Fatalf("auto-correct %v: got %d discrepancies, want 1", autoCorrect, len(repo.discrepancies))
+		}
+		if d := repo.discrepancies[0]; d.ReportedStatus != models.StatusNotRegistered || d.ExpectedAccrual != 100 || d.ReportedAccrual != 0 {
+			t.Fatalf("auto-correct %v: discrepancy %+v", autoCorrect, d)
+		}
+		if len(repo.adjustments) != 0 {
+			t.Fatalf("auto-correct %v: not registered order was corrected automatically: %+v", autoCorrect, repo.adjustments)
+		}
+		if len(repo.reconciled) != 1 {
+			t.Fatalf("auto-correct %v: reconciled %v", autoCorrect, repo.reconciled)
+		}
+	}
+}
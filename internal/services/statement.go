@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/infrastructure/tracing"
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+)
+
+type StatementRepository interface {
+	GetBalanceByUserIDAt(ctx context.Context, userID int64, at time.Time) (*models.Balance, error)
+	StreamTransactionsByPeriod(ctx context.Context, userID int64, from, to time.Time, fn func(*models.Transaction) error) error
+}
+
+type StatementWriter interface {
+	WriteOpening(from, to time.Time, balance float64) error
+	WriteEntry(t *models.Transaction) error
+	WriteClosing(balance float64) error
+}
+
+type StatementService struct {
+	repo StatementRepository
+}
+
+func NewStatementService(repo StatementRepository) *StatementService {
+	return &StatementService{
+		repo: repo,
+	}
+}
+
+func (ss *StatementService) GenerateStatement(ctx context.Context, userID int64, from, to time.Time, sw StatementWriter) error {
+	ctx, span := tracing.Start(ctx, "StatementService.GenerateStatement")
+	defer span.End()
+
+	opening, err := ss.repo.GetBalanceByUserIDAt(ctx, userID, from)
+	if err != nil {
+		return fmt.Errorf("failed to get opening balance: %w", err)
+	}
+
+	if err = sw.WriteOpening(from, to, opening.Current); err != nil {
+		return fmt.Errorf("failed to write opening balance: %w", err)
+	}
+
+	running := opening.Current
+	err = ss.repo.StreamTransactionsByPeriod(ctx, userID, from, to, func(t *models.Transaction) error {
+		running += t.Amount
+		t.Balance = running
+		return sw.WriteEntry(t)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write statement entries: %w", err)
+	}
+
+	closing, err := ss.repo.GetBalanceByUserIDAt(ctx, userID, to)
+	if err != nil {
+		return fmt.Errorf("failed to get closing balance: %w", err)
+	}
+
+	if err = sw.WriteClosing(closing.Current); err != nil {
+		return fmt.Errorf("failed to write closing balance: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+)
+
+type fakeStatementRepo struct {
+	txs []models.Transaction
+}
+
+func (r *fakeStatementRepo) GetBalanceByUserIDAt(_ context.Context, _ int64, at time.Time) (*models.Balance, error) {
+	var b models.Balance
+	for _, t := range r.txs {
+		if t.CreatedAt.Before(at) {
+			b.Current += t.Amount
+		}
+	}
+	return &b, nil
+}
+
+func (r *fakeStatementRepo) StreamTransactionsByPeriod(_ context.Context, _ int64, from, to time.Time, fn func(*models.Transaction) error) error {
+	for _, t := range r.txs {
+		if !t.CreatedAt.Before(from) && t.CreatedAt.Before(to) {
+			if err := fn(&t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type recordingStatementWriter struct {
+	opening, closing float64
+	entries          []models.Transaction
+}
+
+func (w *recordingStatementWriter) WriteOpening(_, _ time.Time, balance float64) error {
+	w.opening = balance
+	return nil
+}
+
+func (w *recordingStatementWriter) WriteEntry(t *models.Transaction) error {
+	w.entries = append(w.entries, *t)
+	return nil
+}
+
+func (w *recordingStatementWriter) WriteClosing(balance float64) error {
+	w.closing = balance
+	return nil
+}
+
+func TestGenerateStatementBalancesAddUp(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	repo := &fakeStatementRepo{txs: []models.Transaction{
+		{Amount: 500, CreatedAt: from.Add(-time.Hour)},
+		{Amount: -120.5, CreatedAt: from.Add(-time.Minute)},
+		{Amount: 300, CreatedAt: from},
+		{Amount: -50.25, CreatedAt: from.Add(48 * time.Hour)},
+		{Amount: 0.1, CreatedAt: from.Add(72 * time.Hour)},
+		{Amount: 0.2, CreatedAt: to.Add(-time.Nanosecond)},
+		{Amount: 1000, CreatedAt: to},
+	}}
+
+	w := &recordingStatementWriter{}
+	if err := NewStatementService(repo).GenerateStatement(context.Background(), 1, from, to, w); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	if w.opening != 379.5 {
+		t.Fatalf("opening %v, want 379.5", w.opening)
+	}
+	if len(w.entries) != 4 {
+		t.Fatalf("got %d entries, want 4 inside [from, to)", len(w.entries))
+	}
+
+	sum := w.opening
+	for i, e := range w.entries {
+		sum += e.Amount
+		if math.Abs(e.Balance-sum) > 1e-9 {
+			t.Fatalf("entry %d running balance %v, want %v", i, e.Balance, sum)
+		}
+	}
+	if math.Abs(sum-w.closing) > 1e-9 {
+		t.Fatalf("opening %v plus entries gives %v, closing is %v", w.opening, sum, w.closing)
+	}
+	if last := w.entries[len(w.entries)-1].Balance; math.Abs(last-w.closing) > 1e-9 {
+		t.Fatalf("last running balance %v, closing %v", last, w.closing)
+	}
+}
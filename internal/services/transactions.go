@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Pro100x3mal/yp-gophermart.git/internal/models"
+)
+
+type TransactionsRepository interface {
+	GetTransactionsByUserID(ctx context.Context, userID int64, limit, offset int) ([]models.Transaction, error)
+}
+
+type TransactionsService struct {
+	repo TransactionsRepository
+}
+
+func NewTransactionsService(repo TransactionsRepository) *TransactionsService {
+	return &TransactionsService{
+		repo: repo,
+	}
+}
+
+func (ts *TransactionsService) ListTransactions(ctx context.Context, userID int64, limit, offset int) ([]models.Transaction, error) {
+	list, err := ts.repo.GetTransactionsByUserID(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get list of transactions: %w", err)
+	}
+	return list, nil
+}